@@ -3,8 +3,46 @@ package api
 // Action ...
 type Action interface {
 	AssignTo(prop string) Action
+	// Filter allow client filter on name mapped to column expression, operators restrict allowed filter types. Filter not declared is rejected. Only applicable for select query action.
+	Filter(name, column string, operators ...string) Action
+	// Sort allow client sort on name mapped to column expression. Sort not declared is rejected. Only applicable for select query action.
+	Sort(name, column string) Action
+	// MaxPageSize maximum page count client may request, default 1000. Select without page count from client also limited to this number of rows. Only applicable for select query action.
+	MaxPageSize(size int) Action
+	// WithTotal return total rows matching filters as page.total in response, or pages.<property>.total when route has multiple paginated actions. Only applicable for select query action.
+	WithTotal() Action
+	// Cursor use keyset pagination ordered by unique column instead of offset, opaque cursor for next page returned as page.next in response, or pages.<property>.next when route has multiple paginated actions. Only applicable for select query action.
+	Cursor(column string, desc bool) Action
+	// UseDatabase execute query on named connection added using Server.AddDatabase, default to route connection. Only applicable for query action.
+	UseDatabase(name string) Action
+	// Replica execute select or get query on read replica when no transaction started, even if route transaction is TxReadWrite. Replica used by default only for TxReadOnly and TxNone route, never for locking read (ex: FOR UPDATE). Only applicable for query action.
+	Replica() Action
+	// If execute action only when condition true, ex: `$user != null`, `status == active`, `:id > 0`, `!$orders`. Invalid condition returned as error by Serve.
+	If(condition string) Action
+	// ElseIf execute action when condition true and no previous action of the same If chain executed
+	ElseIf(condition string) Action
+	// Else execute action when no previous action of the same If chain executed
+	Else() Action
+	// Parallel execute action concurrently with adjacent parallel actions, outside request transaction. Results and response headers merged into response after all finished.
+	// Insert, update and delete query and Context.Tx rejected in parallel action, session changes of parallel action discarded.
+	Parallel() Action
+	// Cast convert column of select result to bool, int, float, string, decimal (number as string) or json (parse JSON text). Unsupported type returned as error by Serve. Only applicable for query action.
+	Cast(column, typ string) Action
+	// Rename rename column of select result. Only applicable for query action.
+	Rename(column, name string) Action
+	// Omit remove columns from select result. Only applicable for query action.
+	Omit(columns ...string) Action
+	// Nest move columns prefixed with prefix and dot into nested object, ex: `author.name` into {"author": {"name": ...}}. Only applicable for query action.
+	Nest(prefix string) Action
+	// NestArray move columns prefixed with prefix and dot into nested array, rows merged by GroupBy have their arrays concatenated. Only applicable for query action.
+	NestArray(prefix string) Action
+	// GroupBy merge rows having the same column value, use with NestArray to group joined rows. Only applicable for query action.
+	GroupBy(column string) Action
+	// AttachTo attach result rows to field of parent rows where parentKey of parent equal childKey of child, instead of writing result. Parent is $var or response property. Only applicable for query action.
+	AttachTo(parent, field, parentKey, childKey string) Action
 
 	execute(*Context) error
+	flow() *actionFlow
 	property() string
 	params() []string
 }
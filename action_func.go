@@ -6,6 +6,7 @@ type actionFunc struct {
 	Action
 	prop string
 	f    func(*Context) error
+	fl   actionFlow
 }
 
 func (f *actionFunc) AssignTo(prop string) Action {
@@ -13,6 +14,86 @@ func (f *actionFunc) AssignTo(prop string) Action {
 	return f
 }
 
+func (f *actionFunc) Filter(name, column string, operators ...string) Action {
+	return f
+}
+
+func (f *actionFunc) Sort(name, column string) Action {
+	return f
+}
+
+func (f *actionFunc) MaxPageSize(size int) Action {
+	return f
+}
+
+func (f *actionFunc) WithTotal() Action {
+	return f
+}
+
+func (f *actionFunc) Cursor(column string, desc bool) Action {
+	return f
+}
+
+func (f *actionFunc) UseDatabase(name string) Action {
+	return f
+}
+
+func (f *actionFunc) Replica() Action {
+	return f
+}
+
+func (f *actionFunc) If(condition string) Action {
+	f.fl.setBranch(branchIf, condition)
+	return f
+}
+
+func (f *actionFunc) ElseIf(condition string) Action {
+	f.fl.setBranch(branchElseIf, condition)
+	return f
+}
+
+func (f *actionFunc) Else() Action {
+	f.fl.setBranch(branchElse, ``)
+	return f
+}
+
+func (f *actionFunc) Parallel() Action {
+	f.fl.parallel = true
+	return f
+}
+
+func (f *actionFunc) Cast(column, typ string) Action {
+	return f
+}
+
+func (f *actionFunc) Rename(column, name string) Action {
+	return f
+}
+
+func (f *actionFunc) Omit(columns ...string) Action {
+	return f
+}
+
+func (f *actionFunc) Nest(prefix string) Action {
+	return f
+}
+
+func (f *actionFunc) NestArray(prefix string) Action {
+	return f
+}
+
+func (f *actionFunc) GroupBy(column string) Action {
+	return f
+}
+
+func (f *actionFunc) AttachTo(parent, field, parentKey, childKey string) Action {
+	return f
+}
+
+func (f *actionFunc) flow() *actionFlow {
+	return &f.fl
+}
+
 func (f *actionFunc) execute(ctx *Context) error {
 	if f.f == nil {
 		return errors.New(`nil func`)
@@ -1,10 +1,11 @@
 package api
 
 import (
+	"encoding/base64"
 	"fmt"
+	"reflect"
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/eqto/dbm"
 	"github.com/eqto/dbm/stmt"
@@ -20,7 +21,14 @@ const (
 	queryTypeDelete
 )
 
+// defaultMaxPageSize row limit of select without page count and maximum page count client may request
+const defaultMaxPageSize = 1000
+
 var (
+	identifierRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+	// lockingReadRegex mysql FOR UPDATE, FOR SHARE, LOCK IN SHARE MODE and sqlserver lock hints
+	lockingReadRegex = regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b|\b(UPDLOCK|XLOCK|HOLDLOCK)\b`)
+
 	errMissingParameter = errors.New(`error missing required parameter: %s`)
 	errExecutingQuery   = errors.New(`error executing query`)
 )
@@ -32,9 +40,219 @@ type actionQuery struct {
 	qType     uint8
 	qProperty string
 	qParams   []string
+	qTypes    map[string]string
 
 	arrayName  string
 	selectStmt *stmt.Select
+
+	filters     map[string]queryFilter
+	sorts       map[string]string
+	maxPageSize int
+	withTotal   bool
+	cursor      *queryCursor
+	dbName      string
+	replica     bool
+
+	fl    actionFlow
+	shape resultShape
+}
+
+// queryCursor keyset pagination on unique column
+type queryCursor struct {
+	column string
+	key    string
+	desc   bool
+}
+
+func (c *queryCursor) orderBy() string {
+	if c.desc {
+		return c.column + ` DESC`
+	}
+	return c.column + ` ASC`
+}
+
+func (c *queryCursor) condition() string {
+	if c.desc {
+		return c.column + ` < ?`
+	}
+	return c.column + ` > ?`
+}
+
+// encode return opaque cursor of last row
+func (c *queryCursor) encode(row dbm.Resultset) string {
+	return base64.RawURLEncoding.EncodeToString(json.Object{`v`: row[c.key]}.Bytes())
+}
+
+func (c *queryCursor) decode(cursor string) (interface{}, error) {
+	b, e := base64.RawURLEncoding.DecodeString(cursor)
+	if e != nil {
+		return nil, errors.New(`invalid cursor`)
+	}
+	js, e := json.Parse(b)
+	if e != nil || !js.Has(`v`) {
+		return nil, errors.New(`invalid cursor`)
+	}
+	return js.Get(`v`), nil
+}
+
+func (q *actionQuery) WithTotal() Action {
+	q.withTotal = true
+	return q
+}
+
+func (q *actionQuery) Cursor(column string, desc bool) Action {
+	key := column
+	if idx := strings.LastIndex(column, `.`); idx >= 0 {
+		key = column[idx+1:]
+	}
+	q.cursor = &queryCursor{column: column, key: key, desc: desc}
+	return q
+}
+
+type queryFilter struct {
+	column    string
+	operators map[string]struct{}
+}
+
+func (q *actionQuery) Filter(name, column string, operators ...string) Action {
+	if q.filters == nil {
+		q.filters = make(map[string]queryFilter)
+	}
+	f := queryFilter{column: column}
+	if len(operators) > 0 {
+		f.operators = make(map[string]struct{})
+		for _, op := range operators {
+			f.operators[normalizeOperator(op)] = struct{}{}
+		}
+	}
+	q.filters[name] = f
+	return q
+}
+
+func (q *actionQuery) Sort(name, column string) Action {
+	if q.sorts == nil {
+		q.sorts = make(map[string]string)
+	}
+	q.sorts[name] = column
+	return q
+}
+
+func (q *actionQuery) UseDatabase(name string) Action {
+	q.dbName = name
+	return q
+}
+
+func (q *actionQuery) Replica() Action {
+	q.replica = true
+	return q
+}
+
+// useReplica read on replica only when route not writing in transaction or action opt in, locking read always on primary
+func (q *actionQuery) useReplica(ctx *Context) bool {
+	if q.qType != queryTypeSelect && q.qType != queryTypeGet || lockingReadRegex.MatchString(q.rawSql) {
+		return false
+	}
+	return q.replica || ctx.tx.mode == TxReadOnly || ctx.tx.mode == TxNone
+}
+
+func (q *actionQuery) If(condition string) Action {
+	q.fl.setBranch(branchIf, condition)
+	return q
+}
+
+func (q *actionQuery) ElseIf(condition string) Action {
+	q.fl.setBranch(branchElseIf, condition)
+	return q
+}
+
+func (q *actionQuery) Else() Action {
+	q.fl.setBranch(branchElse, ``)
+	return q
+}
+
+func (q *actionQuery) Parallel() Action {
+	q.fl.parallel = true
+	return q
+}
+
+func (q *actionQuery) Cast(column, typ string) Action {
+	switch typ {
+	case castBool, castInt, castFloat, castString, castDecimal, castJSON:
+	default:
+		q.fl.err = fmt.Errorf(`unsupported cast type %s for %s`, typ, column)
+		return q
+	}
+	if q.shape.casts == nil {
+		q.shape.casts = make(map[string]string)
+	}
+	q.shape.casts[column] = typ
+	return q
+}
+
+func (q *actionQuery) Rename(column, name string) Action {
+	if q.shape.renames == nil {
+		q.shape.renames = make(map[string]string)
+	}
+	q.shape.renames[column] = name
+	return q
+}
+
+func (q *actionQuery) Omit(columns ...string) Action {
+	if q.shape.omits == nil {
+		q.shape.omits = make(map[string]struct{})
+	}
+	for _, column := range columns {
+		q.shape.omits[column] = struct{}{}
+	}
+	return q
+}
+
+func (q *actionQuery) Nest(prefix string) Action {
+	q.shape.nests = append(q.shape.nests, resultNest{prefix: prefix})
+	return q
+}
+
+func (q *actionQuery) NestArray(prefix string) Action {
+	q.shape.nests = append(q.shape.nests, resultNest{prefix: prefix, array: true})
+	return q
+}
+
+func (q *actionQuery) GroupBy(column string) Action {
+	q.shape.groupBy = column
+	return q
+}
+
+func (q *actionQuery) AttachTo(parent, field, parentKey, childKey string) Action {
+	q.shape.attach = &resultAttach{parent: parent, field: field, parentKey: parentKey, childKey: childKey}
+	return q
+}
+
+func (q *actionQuery) flow() *actionFlow {
+	return &q.fl
+}
+
+func (q *actionQuery) MaxPageSize(size int) Action {
+	q.maxPageSize = size
+	return q
+}
+
+func (q *actionQuery) pageSize() int {
+	if q.maxPageSize > 0 {
+		return q.maxPageSize
+	}
+	return defaultMaxPageSize
+}
+
+func (q *actionQuery) sortString(name, direction string) (string, error) {
+	direction = strings.ToUpper(strings.TrimSpace(direction))
+	if direction != `ASC` && direction != `DESC` {
+		return ``, fmt.Errorf(`invalid sort direction: %s`, direction)
+	}
+	column, ok := q.sorts[name]
+	if !ok {
+		return ``, fmt.Errorf(`sort not allowed: %s`, name)
+	}
+	return fmt.Sprintf(`%s %s`, column, direction), nil
 }
 
 func (q *actionQuery) AssignTo(prop string) Action {
@@ -55,12 +273,27 @@ func (q *actionQuery) executeItem(ctx *Context, values []interface{}) (interface
 	var err error
 	var selectStmt *stmt.Select
 
+	countSql := ``
+	var countValues []interface{}
+
+	dbName := q.dbName
+	if dbName == `` {
+		dbName = ctx.dbName
+	}
+	db := ctx.s.database(dbName)
+	if db == nil {
+		ctx.debugLog.logErr(fmt.Errorf(`database not found: %s`, dbName))
+		return nil, errors.New(`database connection failed`)
+	}
+	driver := db.cn.Driver()
+	cursorCount := 0
+
 	if (q.qType == queryTypeSelect || q.qType == queryTypeGet) && q.selectStmt != nil {
 		//example filter for books title contains word = 'Programming'
 		// {
 		//   "title": {
 		//     "value": "Programming",
-		//     "filter": "fulltext"
+		//     "type": "fulltext"
 		//   }
 		// }
 		js := ctx.req.JSON()
@@ -72,15 +305,39 @@ func (q *actionQuery) executeItem(ctx *Context, values []interface{}) (interface
 
 		if filters := js.GetJSONObject(`filters`); len(filters) > 0 {
 			for key := range filters {
-				js := filters.GetJSONObject(key)
-				parsed := parseFilter(key, js.GetString(`value`), js.GetString(`type`), selectStmt)
-				values = append(values, parsed...)
+				var cond string
+				var vals []interface{}
+				var e error
+				if group := filterGroupOf(key); group != `` {
+					cond, vals, e = q.buildFilterGroup(filters.GetArray(key), group)
+				} else {
+					cond, vals, e = q.buildFilter(key, filters.GetJSONObject(key))
+				}
+				if e != nil {
+					return nil, ctx.StatusBadRequest(e.Error())
+				}
+				selectStmt.Where(cond)
+				values = append(values, vals...)
 			}
 		} else if filters := js.GetArray(`filters`); len(filters) > 0 {
 			for _, filter := range filters {
-				parsed := parseFilter(filter.GetString(`name`), filter.GetString(`value`), filter.GetString(`type`), selectStmt)
-				values = append(values, parsed...)
+				cond, vals, e := q.buildFilterItem(filter)
+				if e != nil {
+					return nil, ctx.StatusBadRequest(e.Error())
+				}
+				selectStmt.Where(cond)
+				values = append(values, vals...)
+			}
+		}
+		if q.withTotal && q.qType == queryTypeSelect {
+			countStmt := new(stmt.Select)
+			if e := stmt.Copy(countStmt, selectStmt); e != nil {
+				return nil, e
 			}
+			countStmt.OrderBy(``)
+			countStmt.Limit(0, 0)
+			countSql = fmt.Sprintf(`SELECT COUNT(*) AS total FROM (%s) t`, driver.StatementString(countStmt))
+			countValues = append([]interface{}{}, values...)
 		}
 		// Example:
 		// {
@@ -89,21 +346,27 @@ func (q *actionQuery) executeItem(ctx *Context, values []interface{}) (interface
 		// 	   "direction": "asc"
 		//   }
 		// }
+		sorts := js.GetArray(`sort`)
 		if sort := js.GetJSONObject(`sort`); sort != nil {
+			sorts = []json.Object{sort}
+		}
+		sortStrings := []string{}
+		for _, sort := range sorts {
 			if active := sort.GetString(`active`); active != `` {
-				selectStmt.OrderBy(fmt.Sprintf(`%s %s`, active, sort.GetStringOr(`direction`, `asc`)))
-			}
-		} else if sorts := js.GetArray(`sort`); len(sorts) > 0 {
-			sortStrings := []string{}
-			for _, sort := range sorts {
-				if active := sort.GetString(`active`); active != `` {
-					sortStrings = append(sortStrings, fmt.Sprintf(`%s %s`, active, sort.GetStringOr(`direction`, `asc`)))
+				sortStr, e := q.sortString(active, sort.GetStringOr(`direction`, `asc`))
+				if e != nil {
+					return nil, ctx.StatusBadRequest(e.Error())
 				}
+				sortStrings = append(sortStrings, sortStr)
 			}
+		}
+		if q.cursor != nil && q.qType == queryTypeSelect {
 			if len(sortStrings) > 0 {
-				selectStmt.OrderBy(strings.Join(sortStrings, `,`))
+				return nil, ctx.StatusBadRequest(`sort is not supported with cursor pagination`)
 			}
-
+			selectStmt.OrderBy(q.cursor.orderBy())
+		} else if len(sortStrings) > 0 {
+			selectStmt.OrderBy(strings.Join(sortStrings, `,`))
 		}
 		// Example:
 		// {
@@ -112,17 +375,57 @@ func (q *actionQuery) executeItem(ctx *Context, values []interface{}) (interface
 		// 	   "count": 100
 		//   }
 		// }
-		if page := js.GetJSONObject(`page`); page != nil {
+		// Cursor pagination example, cursor taken from page.next of previous response:
+		// {
+		//   "page": {
+		// 	   "cursor": "eyJ2Ijo0Mn0",
+		// 	   "count": 100
+		//   }
+		// }
+		if q.cursor != nil && q.qType == queryTypeSelect {
+			cursorCount = q.pageSize()
+			if page := js.GetJSONObject(`page`); page != nil {
+				if page.GetInt(`offset`) > 0 {
+					return nil, ctx.StatusBadRequest(`offset is not supported with cursor pagination`)
+				}
+				if count := page.GetInt(`count`); count > 0 {
+					if count > q.pageSize() {
+						return nil, ctx.StatusBadRequest(fmt.Sprintf(`page count exceeds maximum of %d`, q.pageSize()))
+					}
+					cursorCount = count
+				}
+				if cursor := page.GetString(`cursor`); cursor != `` {
+					val, e := q.cursor.decode(cursor)
+					if e != nil {
+						return nil, ctx.StatusBadRequest(e.Error())
+					}
+					selectStmt.Where(q.cursor.condition())
+					values = append(values, val)
+				}
+			}
+			selectStmt.Count(cursorCount + 1)
+		} else if page := js.GetJSONObject(`page`); page != nil {
 			if offset := page.GetInt(`offset`); offset > 0 {
 				selectStmt.Offset(offset)
 			}
 			if count := page.GetInt(`count`); count > 0 {
+				if count > q.pageSize() {
+					return nil, ctx.StatusBadRequest(fmt.Sprintf(`page count exceeds maximum of %d`, q.pageSize()))
+				}
 				selectStmt.Count(count)
 			}
 		}
 	}
 
-	tx, e := ctx.Tx()
+	if q.qType != queryTypeSelect && q.qType != queryTypeGet {
+		if ctx.tx.mode == TxReadOnly {
+			return nil, errReadOnlyTx
+		}
+		if ctx.parallel {
+			return nil, errParallelTx
+		}
+	}
+	tx, e := ctx.runner(dbName, q.useReplica(ctx))
 	if e != nil {
 		ctx.debugLog.logErr(errors.Wrap(e, `database connection failed`))
 		return nil, errors.New(`database connection failed`)
@@ -133,15 +436,15 @@ func (q *actionQuery) executeItem(ctx *Context, values []interface{}) (interface
 		sql := q.rawSql
 		if selectStmt != nil {
 			if _, count := stmt.LimitOf(selectStmt); count == 0 {
-				selectStmt.Count(1000)
+				selectStmt.Count(q.pageSize())
 			}
-			sql = ctx.s.cn.Driver().StatementString(selectStmt)
+			sql = driver.StatementString(selectStmt)
 		}
 		data, err = tx.Select(sql, values...)
 	case queryTypeGet:
 		sql := q.rawSql
 		if selectStmt != nil {
-			sql = ctx.s.cn.Driver().StatementString(selectStmt)
+			sql = driver.StatementString(selectStmt)
 		}
 		res, e := tx.Get(sql, values...)
 		if e != nil {
@@ -167,9 +470,40 @@ func (q *actionQuery) executeItem(ctx *Context, values []interface{}) (interface
 			ctx.debugLog.logErr(errors.Wrap(e, `duplicate entry`))
 			return nil, errors.New(`duplicate entry`)
 		}
+		if isRetryableErr(err) {
+			ctx.retryable = true
+		}
 		ctx.debugLog.logErr(fmt.Errorf(`%s. Query: %s`, err, q.rawSql))
 		return nil, errExecutingQuery
 	}
+	if q.qType == queryTypeSelect && (countSql != `` || cursorCount > 0) {
+		page := json.Object{}
+		if cursorCount > 0 {
+			var next interface{}
+			rows := data.([]dbm.Resultset)
+			if len(rows) > cursorCount {
+				rows = rows[:cursorCount]
+				next = q.cursor.encode(rows[cursorCount-1])
+			}
+			data = rows
+			page.Put(`next`, next)
+		}
+		if countSql != `` {
+			rs, e := tx.Get(countSql, countValues...)
+			if e != nil {
+				if isRetryableErr(e) {
+					ctx.retryable = true
+				}
+				ctx.debugLog.logErr(fmt.Errorf(`%s. Query: %s`, e, countSql))
+				return nil, errExecutingQuery
+			}
+			page.Put(`total`, rs.Int(`total`))
+		}
+		ctx.resp.setPage(q.qProperty, page)
+	}
+	if q.qType == queryTypeInsert || q.qType == queryTypeUpdate || q.qType == queryTypeDelete {
+		ctx.invalidateTags = append(ctx.invalidateTags, queryTables(q.rawSql)...)
+	}
 	switch q.qType {
 	case queryTypeInsert:
 		if id, e := data.(*dbm.Result).LastInsertID(); e == nil {
@@ -185,53 +519,45 @@ func (q *actionQuery) executeItem(ctx *Context, values []interface{}) (interface
 	return data, nil
 }
 
-// return value
-func parseFilter(name, value, typ string, selectStmt *stmt.Select) []interface{} {
-	values := []interface{}{}
-
-	switch strings.ToUpper(typ) {
-	case `FULLTEXT`:
-		selectStmt.Where(fmt.Sprintf(`MATCH(%s) AGAINST(? IN BOOLEAN MODE)`, name))
-		values = append(values, value+`*`)
-	case `DATE`:
-		selectStmt.Where(fmt.Sprintf(`%s >= ?`, name))
-		selectStmt.Where(fmt.Sprintf(`%s < ?`, name))
-		time, _ := time.Parse(`2006-01-02`, value)
-		values = append(values, time.Format(`2006-01-02`))
-		time = time.AddDate(0, 0, 1)
-		values = append(values, time.Format(`2006-01-02`))
-	case `IN`:
-		vals := strings.Split(value, `,`)
-		names := []string{}
-		for _, val := range vals {
-			names = append(names, `?`)
-			values = append(values, strings.TrimSpace(val))
-		}
-		selectStmt.Where(fmt.Sprintf(`%s IN (%s)`, name, strings.Join(names, `,`)))
-	default:
-		if typ == `` {
-			typ = `=`
+func (q *actionQuery) populateValues(ctx *Context, item interface{}) ([]interface{}, error) {
+	values, e := q.rawValues(ctx, item)
+	if e != nil {
+		return nil, e
+	}
+	for i, param := range q.qParams {
+		if typ, ok := q.qTypes[param]; ok {
+			val, e := coerceParam(param, typ, values[i])
+			if e != nil {
+				return nil, ctx.StatusBadRequest(e.Error())
+			}
+			values[i] = val
 		}
-		selectStmt.Where(fmt.Sprintf(`%s %s ?`, name, typ))
-		values = append(values, value)
 	}
-	return values
+	return values, nil
 }
 
-func (q *actionQuery) populateValues(ctx *Context, item interface{}) ([]interface{}, error) {
+func (q *actionQuery) rawValues(ctx *Context, item interface{}) ([]interface{}, error) {
 	values := []interface{}{}
 	for _, param := range q.qParams {
-		if strings.HasPrefix(param, `$session.`) {
-			values = append(values, ctx.sess.GetString(param[9:]))
-		} else if strings.HasPrefix(param, `$`) {
-			values = append(values, ctx.vars.Get(param[1:]))
-		} else if strings.HasPrefix(param, q.arrayName+`[`) && strings.HasSuffix(param, `]`) {
+		if q.arrayName != `` && strings.HasPrefix(param, q.arrayName+`[`) && strings.HasSuffix(param, `]`) {
+			key := param[len(q.arrayName)+1 : len(param)-1]
 			if js, ok := item.(json.Object); ok {
-				val := js.Get(param[len(q.arrayName)+1 : len(param)-1])
-				values = append(values, val)
+				values = append(values, js.Get(key))
+			} else if key != `` && reflect.ValueOf(item).Kind() == reflect.Map {
+				values = append(values, fieldOf(item, key))
 			} else {
-				values = append(values, item)
+				values = append(values, derefValue(item))
+			}
+		} else if strings.HasPrefix(param, `$session.`) {
+			values = append(values, ctx.Session().GetString(param[9:]))
+		} else if strings.HasPrefix(param, `$`) {
+			values = append(values, ctx.vars.Get(param[1:]))
+		} else if strings.HasPrefix(param, `:`) {
+			name := param[1:]
+			if _, ok := ctx.params[name]; !ok {
+				return nil, fmt.Errorf(errMissingParameter.Error(), param)
 			}
+			values = append(values, ctx.params[name])
 		} else {
 			val := ctx.req.get(param)
 			if val == nil {
@@ -246,46 +572,54 @@ func (q *actionQuery) populateValues(ctx *Context, item interface{}) ([]interfac
 func (q *actionQuery) execute(ctx *Context) error {
 	if q.arrayName != `` { //execute array
 		result := []interface{}{}
+		executeItem := func(item interface{}) error {
+			values, e := q.populateValues(ctx, item)
+			if e != nil {
+				return e
+			}
+			r, e := q.executeItem(ctx, values)
+			if e != nil {
+				return e
+			}
+			if r, e = q.shape.apply(r); e != nil {
+				return e
+			}
+			val := reflect.ValueOf(r)
+			if _, ok := r.(*dbm.Result); !ok && val.Kind() == reflect.Slice {
+				for i := 0; i < val.Len(); i++ {
+					result = append(result, val.Index(i).Interface())
+				}
+			} else {
+				result = append(result, r)
+			}
+			return nil
+		}
 
+		if strings.HasPrefix(q.arrayName, `$`) { //loop over result of previous action, ex: $orders[id]
+			if items := reflect.ValueOf(ctx.lookup(q.arrayName)); items.Kind() == reflect.Slice {
+				for i := 0; i < items.Len(); i++ {
+					if e := executeItem(items.Index(i).Interface()); e != nil {
+						return e
+					}
+				}
+			}
+			return q.write(ctx, result)
+		}
 		js := ctx.req.JSON()
 		if objs := js.GetArray(q.arrayName); objs != nil {
 			for _, obj := range objs {
-				values, e := q.populateValues(ctx, obj)
-				if e != nil {
-					return e
-				}
-				r, e := q.executeItem(ctx, values)
-				if e != nil {
+				if e := executeItem(obj); e != nil {
 					return e
 				}
-				if recs, ok := r.([]dbm.Resultset); ok {
-					for _, rec := range recs {
-						result = append(result, rec)
-					}
-				} else {
-					result = append(result, r)
-				}
 			}
 		} else if arr := js.Array(q.arrayName); arr != nil {
 			for _, val := range arr {
-				values, e := q.populateValues(ctx, val)
-				if e != nil {
-					return e
-				}
-				r, e := q.executeItem(ctx, values)
-				if e != nil {
+				if e := executeItem(val); e != nil {
 					return e
 				}
-				if recs, ok := r.([]dbm.Resultset); ok {
-					for _, rec := range recs {
-						result = append(result, rec)
-					}
-				} else {
-					result = append(result, r)
-				}
 			}
 		}
-		return ctx.Write(result)
+		return q.write(ctx, result)
 	}
 	values, e := q.populateValues(ctx, nil)
 	if e != nil {
@@ -295,7 +629,18 @@ func (q *actionQuery) execute(ctx *Context) error {
 	if e != nil {
 		return e
 	}
-	return ctx.Write(r)
+	if r, e = q.shape.apply(r); e != nil {
+		return e
+	}
+	return q.write(ctx, r)
+}
+
+// write write result to assigned property, or attach it to parent rows
+func (q *actionQuery) write(ctx *Context, data interface{}) error {
+	if q.shape.attach != nil {
+		return q.shape.attach.apply(ctx, data)
+	}
+	return ctx.Write(data)
 }
 
 func newQueryAction(sql, params string) (*actionQuery, error) {
@@ -323,10 +668,24 @@ func newQueryAction(sql, params string) (*actionQuery, error) {
 
 	params = strings.ReplaceAll(strings.TrimSpace(params), ` `, ``)
 	if params != `` {
-		regex := regexp.MustCompile(`(?Uis)\s*^([a-z0-9._]+)\[([a-z0-9._]*)\]\s*$`)
+		regex := regexp.MustCompile(`(?Uis)\s*^(\$?[a-z0-9._]+)\[([a-z0-9._]*)\]\s*$`)
 
 		act.qParams = strings.Split(params, `,`)
-		for _, val := range act.qParams {
+		for i, val := range act.qParams {
+			if idx := strings.LastIndex(val, `:`); idx > 0 {
+				typ := strings.ToLower(val[idx+1:])
+				switch typ {
+				case `int`, `float`, `bool`, `string`:
+				default:
+					return act, fmt.Errorf(`unsupported parameter type %s for %s`, typ, val)
+				}
+				val = val[:idx]
+				act.qParams[i] = val
+				if act.qTypes == nil {
+					act.qTypes = make(map[string]string)
+				}
+				act.qTypes[val] = typ
+			}
 			matches := regex.FindStringSubmatch(val)
 			if len(matches) == 3 {
 				if act.arrayName != `` && act.arrayName != matches[1] {
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testPageResponse struct {
+	Data []map[string]interface{} `json:"data"`
+	Page struct {
+		Next  *string `json:"next"`
+		Total *int    `json:"total"`
+	} `json:"page"`
+}
+
+// orderRows return orders with id greater than after, up to count rows
+func orderRows(after, count int) *testResult {
+	res := &testResult{cols: []string{`id`, `status`}}
+	for id := after + 1; id <= 5 && len(res.rows) < count; id++ {
+		res.rows = append(res.rows, []driver.Value{int64(id), `open`})
+	}
+	return res
+}
+
+func serveOrders(t *testing.T, configure func(q Action)) (*testDB, func(body string) (int, testPageResponse)) {
+	t.Helper()
+	s := newTestServer()
+	cn, db := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	db.handler = func(query string, args []interface{}) (*testResult, error) {
+		if strings.HasPrefix(query, `SELECT COUNT(*)`) {
+			return &testResult{cols: []string{`total`}, rows: [][]driver.Value{{int64(5)}}}, nil
+		}
+		after := 0
+		if len(args) > 0 {
+			fmt.Sscan(fmt.Sprint(args[0]), &after)
+		}
+		var count int
+		fmt.Sscanf(query[strings.LastIndex(query, `,`)+1:], `%d`, &count)
+		return orderRows(after, count), nil
+	}
+	configure(s.Post(`/orders`).AddQueryAction(`SELECT id, status FROM orders`, ``))
+	client := serveTest(t, s)
+	return db, func(body string) (int, testPageResponse) {
+		t.Helper()
+		resp := doJSON(t, client, MethodPost, `/orders`, body)
+		page := testPageResponse{}
+		if resp.StatusCode() == StatusOK {
+			if e := json.Unmarshal(resp.Body(), &page); e != nil {
+				t.Fatal(e)
+			}
+		}
+		return resp.StatusCode(), page
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	db, request := serveOrders(t, func(q Action) {
+		q.Cursor(`id`, false).Sort(`status`, `status`).MaxPageSize(2)
+	})
+
+	status, page := request(`{}`)
+	if status != StatusOK || len(page.Data) != 2 || page.Page.Next == nil {
+		t.Fatalf(`expected 2 rows with next cursor, got %d %+v`, status, page)
+	}
+	if query, _ := db.last(`SELECT`); query != `SELECT id, status FROM orders ORDER BY id ASC LIMIT 0, 3` {
+		t.Errorf(`unexpected query %s`, query)
+	}
+
+	status, page = request(fmt.Sprintf(`{"page": {"cursor": %q}}`, *page.Page.Next))
+	if status != StatusOK || len(page.Data) != 2 || page.Page.Next == nil {
+		t.Fatalf(`expected second page with next cursor, got %d %+v`, status, page)
+	}
+	query, args := db.last(`SELECT`)
+	if query != `SELECT id, status FROM orders WHERE id > ? ORDER BY id ASC LIMIT 0, 3` || len(args) != 1 || fmt.Sprint(args[0]) != `2` {
+		t.Errorf(`unexpected query %s %v`, query, args)
+	}
+	if id := fmt.Sprint(page.Data[0][`id`]); id != `3` {
+		t.Errorf(`expected second page starting at id 3, got %s`, id)
+	}
+
+	status, page = request(fmt.Sprintf(`{"page": {"cursor": %q}}`, *page.Page.Next))
+	if status != StatusOK || len(page.Data) != 1 || page.Page.Next != nil {
+		t.Errorf(`expected last page without next cursor, got %d %+v`, status, page)
+	}
+
+	for _, body := range []string{
+		`{"page": {"cursor": "invalid"}}`,
+		`{"page": {"offset": 2}}`,
+		`{"page": {"count": 3}}`,
+		`{"sort": {"active": "status"}}`,
+	} {
+		if status, _ := request(body); status != StatusBadRequest {
+			t.Errorf(`%s: expected status 400, got %d`, body, status)
+		}
+	}
+}
+
+func TestTotalPagination(t *testing.T) {
+	db, request := serveOrders(t, func(q Action) {
+		q.WithTotal()
+	})
+
+	status, page := request(`{"page": {"offset": 2, "count": 2}}`)
+	if status != StatusOK || len(page.Data) != 2 {
+		t.Fatalf(`expected 2 rows, got %d %+v`, status, page)
+	}
+	if page.Page.Total == nil || *page.Page.Total != 5 {
+		t.Errorf(`expected total 5, got %v`, page.Page.Total)
+	}
+	if query, _ := db.last(`SELECT COUNT(*)`); query != `SELECT COUNT(*) AS total FROM (SELECT id, status FROM orders) t` {
+		t.Errorf(`unexpected count query %s`, query)
+	}
+	if query, _ := db.last(`SELECT id`); query != `SELECT id, status FROM orders LIMIT 2, 2` {
+		t.Errorf(`unexpected query %s`, query)
+	}
+}
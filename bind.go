@@ -0,0 +1,271 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Handle register typed handler on group. Request values are decoded into In using struct tags:
+//
+//	json:"name"     JSON body (default, field name used if tag not exists)
+//	query:"name"    query string
+//	form:"name"     form value
+//	path:"name"     path parameter, ex: /users/:id
+//	validate:"required,min=1,max=100,enum=a|b,pattern=^[a-z]+$"
+//
+// pattern must be the last rule of validate tag. In is validated before fn is called and Out is written to data property.
+// Invalid validate tag of In logged and every request responded with status 500.
+func Handle[In any, Out any](g *Group, method, path string, fn func(*Context, In) (Out, error)) *Route {
+	route := g.getRoute(method, g.formatPath(path))
+	var tagErr error
+	inType := reflect.TypeOf((*In)(nil)).Elem()
+	if inType.Kind() == reflect.Struct {
+		fields, e := structFields(inType)
+		if e != nil {
+			tagErr = fmt.Errorf(`unable to bind %s %s: %s`, method, path, e)
+			g.s.logger.E(tagErr)
+		} else {
+			route.Validate(fields...)
+		}
+	}
+	if route.doc.response == nil {
+		if field, e := typeField(``, reflect.TypeOf((*Out)(nil)).Elem()); e != nil {
+			g.s.logger.W(e)
+		} else if field != nil {
+			route.ResponseSchema(field.schema())
+		}
+	}
+	route.AddAction(func(ctx *Context) error {
+		if tagErr != nil {
+			return ctx.StatusInternalServerError(tagErr.Error())
+		}
+		var in In
+		if e := bindRequest(ctx, &in); e != nil {
+			return ctx.StatusBadRequest(e.Error())
+		}
+		out, e := fn(ctx, in)
+		if e != nil {
+			return e
+		}
+		return ctx.Write(out)
+	})
+	return route
+}
+
+// bindRequest decode request into dest, dest must be pointer
+func bindRequest(ctx *Context, dest interface{}) error {
+	if body := ctx.req.Body(); len(body) > 0 && strings.HasPrefix(ctx.ContentType(), `application/json`) {
+		if e := json.Unmarshal(body, dest); e != nil {
+			return fmt.Errorf(`invalid request body: %s`, e)
+		}
+	}
+	val := reflect.ValueOf(dest).Elem()
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+	return bindStruct(ctx, val)
+}
+
+func bindStruct(ctx *Context, val reflect.Value) error {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != `` {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if e := bindStruct(ctx, val.Field(i)); e != nil {
+				return e
+			}
+			continue
+		}
+		name, source := fieldTag(sf)
+		if source == `` {
+			continue
+		}
+		field := &Field{name: name, source: source}
+		raw, ok := field.value(ctx).(string)
+		if !ok {
+			continue
+		}
+		if e := setValue(val.Field(i), raw); e != nil {
+			return fmt.Errorf(`invalid value of %s: %s`, name, e)
+		}
+	}
+	return nil
+}
+
+func setValue(val reflect.Value, raw string) error {
+	if val.Kind() == reflect.Ptr {
+		ptr := reflect.New(val.Type().Elem())
+		if e := setValue(ptr.Elem(), raw); e != nil {
+			return e
+		}
+		val.Set(ptr)
+		return nil
+	}
+	switch val.Kind() {
+	case reflect.String:
+		val.SetString(raw)
+	case reflect.Bool:
+		b, e := strconv.ParseBool(raw)
+		if e != nil {
+			return e
+		}
+		val.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, e := strconv.ParseInt(raw, 10, val.Type().Bits())
+		if e != nil {
+			return e
+		}
+		val.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, e := strconv.ParseUint(raw, 10, val.Type().Bits())
+		if e != nil {
+			return e
+		}
+		val.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, e := strconv.ParseFloat(raw, val.Type().Bits())
+		if e != nil {
+			return e
+		}
+		val.SetFloat(f)
+	default:
+		return fmt.Errorf(`unsupported type %s`, val.Type())
+	}
+	return nil
+}
+
+// fieldTag return field name and source, empty source for JSON body
+func fieldTag(sf reflect.StructField) (string, string) {
+	for _, source := range []string{fieldSourcePath, fieldSourceQuery, fieldSourceForm} {
+		if tag := sf.Tag.Get(source); tag != `` && tag != `-` {
+			return tag, source
+		}
+	}
+	name := sf.Name
+	if tag := strings.Split(sf.Tag.Get(`json`), `,`)[0]; tag != `` {
+		name = tag
+	}
+	return name, ``
+}
+
+// structFields create validation fields of struct type, error if validate tag invalid
+func structFields(typ reflect.Type) ([]*Field, error) {
+	fields := []*Field{}
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != `` || sf.Tag.Get(`json`) == `-` {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			embedded, e := structFields(sf.Type)
+			if e != nil {
+				return nil, e
+			}
+			fields = append(fields, embedded...)
+			continue
+		}
+		name, source := fieldTag(sf)
+		field, e := typeField(name, sf.Type)
+		if e != nil {
+			return nil, e
+		}
+		if field == nil {
+			continue
+		}
+		field.source = source
+		if e := applyValidateTag(field, sf.Tag.Get(`validate`)); e != nil {
+			return nil, fmt.Errorf(`invalid validate tag of field %s: %s`, sf.Name, e)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// typeField create validation field from go type, return nil for unsupported type
+func typeField(name string, typ reflect.Type) (*Field, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == timeType {
+		return FieldString(name), nil
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return FieldString(name), nil
+	case reflect.Bool:
+		return FieldBool(name), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return FieldInt(name), nil
+	case reflect.Float32, reflect.Float64:
+		return FieldNumber(name), nil
+	case reflect.Struct:
+		fields, e := structFields(typ)
+		if e != nil {
+			return nil, e
+		}
+		return FieldObject(name, fields...), nil
+	case reflect.Map:
+		return FieldObject(name), nil
+	case reflect.Slice, reflect.Array:
+		item, e := typeField(``, typ.Elem())
+		if e != nil {
+			return nil, e
+		}
+		return FieldArray(name, item), nil
+	}
+	return nil, nil
+}
+
+func applyValidateTag(field *Field, tag string) error {
+	if tag == `` {
+		return nil
+	}
+	rules := strings.Split(tag, `,`)
+	for i, rule := range rules {
+		key, value := rule, ``
+		if idx := strings.Index(rule, `=`); idx >= 0 {
+			key, value = rule[:idx], rule[idx+1:]
+		}
+		switch key {
+		case `required`:
+			field.Required()
+		case `min`, `max`:
+			f, e := strconv.ParseFloat(value, 64)
+			if e != nil {
+				return e
+			}
+			if key == `min` {
+				field.Min(f)
+			} else {
+				field.Max(f)
+			}
+		case `enum`:
+			values := []interface{}{}
+			for _, val := range strings.Split(value, `|`) {
+				values = append(values, val)
+			}
+			field.Enum(values...)
+		case `pattern`:
+			regex, e := regexp.Compile(strings.Join(append([]string{value}, rules[i+1:]...), `,`))
+			if e != nil {
+				return e
+			}
+			field.pattern = regex
+			return nil
+		default:
+			return fmt.Errorf(`unknown rule %s`, key)
+		}
+	}
+	return nil
+}
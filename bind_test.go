@@ -0,0 +1,54 @@
+package api
+
+import "testing"
+
+type bindOrder struct {
+	ID     int    `path:"id"`
+	Status string `query:"status" validate:"enum=open|closed"`
+}
+
+type bindInvalidTag struct {
+	Limit int `query:"limit" validate:"min=abc"`
+}
+
+func TestHandleBindRequest(t *testing.T) {
+	s := newTestServer()
+	var got bindOrder
+	Handle(s.defGroup(), MethodGet, `/orders/:id`, func(ctx *Context, in bindOrder) (bindOrder, error) {
+		got = in
+		return in, nil
+	})
+	client := serveTest(t, s)
+
+	if resp := doTest(t, client, MethodGet, `/orders/7?status=open`, nil); resp.StatusCode() != StatusOK {
+		t.Fatalf(`expected status 200, got %d: %s`, resp.StatusCode(), resp.Body())
+	}
+	if got.ID != 7 || got.Status != `open` {
+		t.Errorf(`expected order 7 open, got %+v`, got)
+	}
+	if resp := doTest(t, client, MethodGet, `/orders/7?status=unknown`, nil); resp.StatusCode() != StatusBadRequest {
+		t.Errorf(`expected status 400 for invalid enum, got %d`, resp.StatusCode())
+	}
+}
+
+func TestHandleInvalidValidateTag(t *testing.T) {
+	s := newTestServer()
+	called := false
+	Handle(s.defGroup(), MethodGet, `/items`, func(ctx *Context, in bindInvalidTag) (int, error) {
+		called = true
+		return in.Limit, nil
+	})
+	resp := doTest(t, serveTest(t, s), MethodGet, `/items?limit=1`, nil)
+	if resp.StatusCode() != StatusInternalServerError {
+		t.Errorf(`expected status 500, got %d`, resp.StatusCode())
+	}
+	if called {
+		t.Error(`handler called with invalid validate tag`)
+	}
+}
+
+func TestValidateTagInvalidPattern(t *testing.T) {
+	if e := applyValidateTag(FieldString(`name`), `pattern=^[a-z+$`); e == nil {
+		t.Error(`expected error for invalid pattern`)
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	tableRegex = regexp.MustCompile("(?i)\\b(?:FROM|JOIN|INTO|UPDATE)\\s+([a-zA-Z0-9_.`\"\\[\\]]+)")
+)
+
+// CacheStore backend of route response cache
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration, tags []string)
+	// InvalidateTag remove all entries having tag
+	InvalidateTag(tag string)
+}
+
+type routeCache struct {
+	ttl         time.Duration
+	sessionKeys []string
+	tags        []string
+}
+
+// cacheState cache of current request
+type cacheState struct {
+	store CacheStore
+	key   string
+	ttl   time.Duration
+	tags  []string
+	hit   bool
+}
+
+// Cache cache response of GET and HEAD request for ttl, keyed on method, path, query string, JSON body, values of sessionKeys and $session values used by query actions and conditions.
+// Secure route without any session value in key not cached, since its response may differ between users. Route with function action cached only when sessionKeys given, since session values read by function unknown. Response has ETag header and request with matching If-None-Match responded with status 304.
+func (r *Route) Cache(ttl time.Duration, sessionKeys ...string) *Route {
+	r.cache = &routeCache{ttl: ttl, sessionKeys: sessionKeys}
+	return r
+}
+
+// CacheTags add tags to cached response in addition to tables read by query actions. Cached responses invalidated when query action write to table with the same name, or InvalidateCache called with the tag.
+func (r *Route) CacheTags(tags ...string) *Route {
+	if r.cache == nil {
+		r.cache = &routeCache{}
+	}
+	r.cache.tags = append(r.cache.tags, tags...)
+	return r
+}
+
+// SetCacheStore set backend of route response cache, default to in-memory LRU store with 1000 entries
+func (s *Server) SetCacheStore(store CacheStore) {
+	s.cacheStore = store
+}
+
+// InvalidateCache remove cached responses having any of tags
+func (s *Server) InvalidateCache(tags ...string) {
+	for _, tag := range tags {
+		s.cacheStore.InvalidateTag(strings.ToLower(tag))
+	}
+}
+
+// cacheTags return explicit tags and tables read by query actions of route
+func (r *Route) cacheTags() []string {
+	tags := []string{}
+	for _, tag := range r.cache.tags {
+		tags = append(tags, strings.ToLower(tag))
+	}
+	for _, act := range r.action {
+		if q, ok := act.(*actionQuery); ok && (q.qType == queryTypeSelect || q.qType == queryTypeGet) {
+			tags = append(tags, queryTables(q.rawSql)...)
+		}
+	}
+	return tags
+}
+
+// cacheSessionKeys return explicit session keys and session values used by query actions and conditions of route
+func (r *Route) cacheSessionKeys() []string {
+	keys := append([]string{}, r.cache.sessionKeys...)
+	seen := map[string]struct{}{}
+	for _, key := range keys {
+		seen[key] = struct{}{}
+	}
+	add := func(name string) {
+		if !strings.HasPrefix(name, `$session.`) {
+			return
+		}
+		if _, ok := seen[name[9:]]; !ok {
+			seen[name[9:]] = struct{}{}
+			keys = append(keys, name[9:])
+		}
+	}
+	for _, act := range r.action {
+		if cond := act.flow().cond; cond != nil {
+			add(cond.operand)
+		}
+		for _, param := range act.params() {
+			add(param)
+		}
+	}
+	return keys
+}
+
+// hasFuncAction return true if route has function action
+func (r *Route) hasFuncAction() bool {
+	for _, act := range r.action {
+		if _, ok := act.(*actionFunc); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCache return true if response loaded from cache
+func (s *Server) loadCache(ctx *Context, route *Route) bool {
+	method := ctx.Method()
+	if route.cache == nil || route.cache.ttl <= 0 || (method != MethodGet && method != MethodHead) {
+		return false
+	}
+	h := sha256.New()
+	h.Write([]byte(method + ` ` + ctx.URL().Path + `?` + ctx.URL().Query().Encode() + "\n"))
+	if body, e := json.Marshal(ctx.req.JSON()); e == nil {
+		h.Write(body)
+	}
+	sessionKeys := route.cacheSessionKeys()
+	if route.secure && len(sessionKeys) == 0 || len(route.cache.sessionKeys) == 0 && route.hasFuncAction() {
+		return false
+	}
+	for _, key := range sessionKeys {
+		val, _ := json.Marshal(ctx.Session().Get(key))
+		h.Write([]byte("\n" + key + `=`))
+		h.Write(val)
+	}
+	state := &cacheState{store: s.cacheStore, key: hex.EncodeToString(h.Sum(nil)), ttl: route.cache.ttl, tags: route.cacheTags()}
+	ctx.cache = state
+
+	value, ok := state.store.Get(state.key)
+	if !ok {
+		return false
+	}
+	parts := bytes.SplitN(value, []byte("\n"), 3)
+	if len(parts) != 3 {
+		return false
+	}
+	status, e := strconv.Atoi(string(parts[0]))
+	if e != nil {
+		return false
+	}
+	state.hit = true
+	resp := ctx.resp.httpResp
+	resp.SetStatusCode(status)
+	ctx.resp.SetContentType(string(parts[1]))
+	resp.SetBody(parts[2])
+	ctx.resp.stop = true
+	return true
+}
+
+// finish store rendered response on cache miss and handle ETag
+func (c *cacheState) finish(ctx *Context) {
+	resp := ctx.resp.httpResp
+	if resp.StatusCode() != StatusOK || ctx.resp.err != nil || resp.IsBodyStream() {
+		return
+	}
+	body := resp.Body()
+	if !c.hit {
+		value := make([]byte, 0, len(body)+64)
+		value = append(value, strconv.Itoa(resp.StatusCode())+"\n"+ctx.resp.ContentType()+"\n"...)
+		value = append(value, body...)
+		c.store.Set(c.key, value, c.ttl, c.tags)
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	ctx.resp.Header().Set(`ETag`, etag)
+	for _, tag := range strings.Split(ctx.req.Header().Get(`If-None-Match`), `,`) {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), `W/`)
+		if tag == etag || tag == `*` {
+			resp.SetStatusCode(StatusNotModified)
+			resp.ResetBody()
+			return
+		}
+	}
+}
+
+// queryTables return lowercase names of tables used by query
+func queryTables(query string) []string {
+	tables := []string{}
+	for _, match := range tableRegex.FindAllStringSubmatch(query, -1) {
+		if name := strings.ToLower(strings.Trim(match[1], "`\"[]")); name != `` && !strings.HasPrefix(name, `(`) {
+			tables = append(tables, name)
+		}
+	}
+	return tables
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     []byte
+	expiredAt time.Time
+	tags      []string
+}
+
+type memoryCacheStore struct {
+	lock       sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	tags       map[string]map[string]struct{}
+}
+
+// NewMemoryCacheStore create in-memory cache store, least recently used entry evicted when store has more than maxEntries
+func NewMemoryCacheStore(maxEntries int) CacheStore {
+	return &memoryCacheStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+func (m *memoryCacheStore) Get(key string) ([]byte, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.expiredAt) {
+		m.remove(el)
+		return nil, false
+	}
+	m.lru.MoveToFront(el)
+	return entry.value, true
+}
+
+func (m *memoryCacheStore) Set(key string, value []byte, ttl time.Duration, tags []string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+	entry := &memoryCacheEntry{key: key, value: value, expiredAt: time.Now().Add(ttl), tags: tags}
+	m.entries[key] = m.lru.PushFront(entry)
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][key] = struct{}{}
+	}
+	for m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+	}
+}
+
+func (m *memoryCacheStore) InvalidateTag(tag string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for key := range m.tags[tag] {
+		if el, ok := m.entries[key]; ok {
+			m.remove(el)
+		}
+	}
+	delete(m.tags, tag)
+}
+
+func (m *memoryCacheStore) remove(el *list.Element) {
+	entry := m.lru.Remove(el).(*memoryCacheEntry)
+	delete(m.entries, entry.key)
+	for _, tag := range entry.tags {
+		if keys := m.tags[tag]; keys != nil {
+			delete(keys, entry.key)
+			if len(keys) == 0 {
+				delete(m.tags, tag)
+			}
+		}
+	}
+}
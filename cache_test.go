@@ -0,0 +1,105 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func cachedContext(t *testing.T, s *Server, route *Route, userID string) (*Context, bool) {
+	t.Helper()
+	ctx := newTestContext(t, s, MethodGet, `/orders?status=open`, nil)
+	if userID != `` {
+		ctx.Session().Put(`user_id`, userID)
+	}
+	return ctx, s.loadCache(ctx, route)
+}
+
+func TestCacheKeyIncludeSessionParams(t *testing.T) {
+	s := newTestServer()
+	route := s.Get(`/orders`).Secure().Cache(time.Minute)
+	route.AddQueryAction(`SELECT * FROM orders WHERE user_id = ?`, `$session.user_id`)
+
+	if keys := route.cacheSessionKeys(); !reflect.DeepEqual(keys, []string{`user_id`}) {
+		t.Fatalf(`expected session key user_id, got %v`, keys)
+	}
+	alice, _ := cachedContext(t, s, route, `alice`)
+	alice2, _ := cachedContext(t, s, route, `alice`)
+	bob, _ := cachedContext(t, s, route, `bob`)
+	if alice.cache == nil || bob.cache == nil {
+		t.Fatal(`secure route with session param not cached`)
+	}
+	if alice.cache.key != alice2.cache.key {
+		t.Error(`same user has different cache key`)
+	}
+	if alice.cache.key == bob.cache.key {
+		t.Error(`different users share cache key`)
+	}
+}
+
+func TestCacheNotSharedBetweenUsers(t *testing.T) {
+	s := newTestServer()
+	route := s.Get(`/orders`).Cache(time.Minute)
+	route.AddQueryAction(`SELECT * FROM orders WHERE user_id = ?`, `$session.user_id`)
+
+	alice, hit := cachedContext(t, s, route, `alice`)
+	if hit {
+		t.Fatal(`unexpected cache hit`)
+	}
+	alice.resp.httpResp.SetBody([]byte(`{"data":"alice orders"}`))
+	alice.cache.finish(alice)
+
+	if _, hit := cachedContext(t, s, route, `alice`); !hit {
+		t.Error(`expected cache hit for the same user`)
+	}
+	if _, hit := cachedContext(t, s, route, `bob`); hit {
+		t.Error(`response of alice served to bob`)
+	}
+}
+
+func TestCacheSkipSecureRouteWithoutSessionKey(t *testing.T) {
+	s := newTestServer()
+	route := s.Get(`/orders`).Secure().Cache(time.Minute)
+	route.AddQueryAction(`SELECT * FROM orders`, ``)
+
+	ctx, hit := cachedContext(t, s, route, `alice`)
+	if hit || ctx.cache != nil {
+		t.Error(`secure route without session key cached`)
+	}
+}
+
+func TestCacheConditionSessionKey(t *testing.T) {
+	s := newTestServer()
+	route := s.Get(`/orders`).Cache(time.Minute, `tenant`)
+	route.AddQueryAction(`SELECT * FROM orders`, ``).If(`$session.role == admin`)
+
+	if keys := route.cacheSessionKeys(); !reflect.DeepEqual(keys, []string{`tenant`, `role`}) {
+		t.Errorf(`expected session keys tenant and role, got %v`, keys)
+	}
+}
+
+func TestQueryTablesOfWrite(t *testing.T) {
+	tables := queryTables("UPDATE orders o JOIN `users` u ON u.id = o.user_id SET o.status = ?")
+	if !reflect.DeepEqual(tables, []string{`orders`, `users`}) {
+		t.Errorf(`expected orders and users, got %v`, tables)
+	}
+}
+
+func TestCacheSkipFuncActionWithoutSessionKey(t *testing.T) {
+	s := newTestServer()
+	route := s.Get(`/orders`).Cache(time.Minute)
+	route.AddAction(func(ctx *Context) error { return ctx.Write(ctx.Session().GetString(`user_id`)) })
+
+	if ctx, _ := cachedContext(t, s, route, `alice`); ctx.cache != nil {
+		t.Error(`route with function action cached without session key`)
+	}
+	route.Cache(time.Minute, `user_id`)
+	alice, _ := cachedContext(t, s, route, `alice`)
+	bob, _ := cachedContext(t, s, route, `bob`)
+	if alice.cache == nil || bob.cache == nil {
+		t.Fatal(`route with function action and session key not cached`)
+	}
+	if alice.cache.key == bob.cache.key {
+		t.Error(`different users share cache key`)
+	}
+}
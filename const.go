@@ -3,17 +3,31 @@ package api
 import "github.com/valyala/fasthttp"
 
 const (
-	MethodGet  string = fasthttp.MethodGet
-	MethodPost string = fasthttp.MethodPost
+	MethodGet     string = fasthttp.MethodGet
+	MethodHead    string = fasthttp.MethodHead
+	MethodPost    string = fasthttp.MethodPost
+	MethodPut     string = fasthttp.MethodPut
+	MethodPatch   string = fasthttp.MethodPatch
+	MethodDelete  string = fasthttp.MethodDelete
+	MethodOptions string = fasthttp.MethodOptions
 
-	StatusBadRequest   = fasthttp.StatusBadRequest
-	StatusUnauthorized = fasthttp.StatusUnauthorized
-	StatusForbidden    = fasthttp.StatusForbidden
-	StatusNotFound     = fasthttp.StatusNotFound
-	StatusOK           = fasthttp.StatusOK
+	StatusBadRequest      = fasthttp.StatusBadRequest
+	StatusUnauthorized    = fasthttp.StatusUnauthorized
+	StatusForbidden       = fasthttp.StatusForbidden
+	StatusNotFound        = fasthttp.StatusNotFound
+	StatusTooManyRequests = fasthttp.StatusTooManyRequests
+	StatusOK              = fasthttp.StatusOK
+	StatusNoContent       = fasthttp.StatusNoContent
+	StatusNotModified     = fasthttp.StatusNotModified
+
+	StatusPermanentRedirect = fasthttp.StatusPermanentRedirect
+
+	StatusMethodNotAllowed = fasthttp.StatusMethodNotAllowed
 
 	StatusInternalServerError = fasthttp.StatusInternalServerError
 	StatusServiceUnavailable  = fasthttp.StatusServiceUnavailable
 
 	StatusBadGateway = fasthttp.StatusBadGateway
 )
+
+var methods = []string{MethodGet, MethodHead, MethodPost, MethodPut, MethodPatch, MethodDelete, MethodOptions}
@@ -24,10 +24,18 @@ type Context struct {
 
 	vars json.Object
 
-	stdTx *dbm.Tx
+	txs       map[string]*dbm.Tx
+	dbName    string
+	tx        txPolicy
+	retryable bool
+	parallel  bool
+
+	cache          *cacheState
+	invalidateTags []string
 
 	debugLog debugLog
 	values   map[string]interface{}
+	params   map[string]string
 }
 
 func (c *Context) Write(value interface{}) error {
@@ -41,7 +49,9 @@ func (c *Context) WriteStream(filename, contentType string, fn func(Writer)) err
 	c.resp.Header().Set(`Content-Disposition`, fmt.Sprintf(`attachment;filename="%s"`, filename))
 	c.resp.Header().Set(`Content-Type`, contentType)
 	sw := c.resp.streamWriter()
+	c.s.addStream(c.resp.writer)
 	go func() {
+		defer c.s.removeStream(c.resp.writer)
 		defer sw.Close()
 		fn(sw)
 	}()
@@ -90,6 +100,10 @@ func (c *Context) StatusNotFound(msg string) error {
 	return c.httpError(StatusNotFound, StatusNotFound, msg)
 }
 
+func (c *Context) StatusMethodNotAllowed(msg string) error {
+	return c.httpError(StatusMethodNotAllowed, StatusMethodNotAllowed, msg)
+}
+
 func (c *Context) StatusServiceUnavailable(msg string) error {
 	return c.httpError(StatusServiceUnavailable, StatusServiceUnavailable, msg)
 }
@@ -103,24 +117,23 @@ func (c *Context) Redirect(url string) error {
 	return nil
 }
 
+// Database return connection of route, set using Route.UseDatabase
 func (c *Context) Database() (*dbm.Connection, error) {
-	if c.s.cn == nil {
+	db := c.s.database(c.dbName)
+	if db == nil {
 		return nil, errors.New(`database not available`)
 	}
-	return c.s.cn, nil
+	return db.cn, nil
 }
 
+// Tx return transaction of route connection, error if route transaction policy is TxNone or TxReadOnly
 func (c *Context) Tx() (*dbm.Tx, error) {
-	cn := c.s.Database()
-	if cn != nil && c.stdTx == nil {
-		tx, e := cn.Begin()
-		if e != nil { //db error
-			c.setErr(e)
-			return nil, c.StatusServiceUnavailable(`Service unavailable`)
-		}
-		c.stdTx = tx
-	}
-	return c.stdTx, nil
+	return c.TxNamed(c.dbName)
+}
+
+// Param return value of path parameter, ex: id for route /users/:id
+func (c *Context) Param(name string) string {
+	return c.params[name]
 }
 
 func (c *Context) URL() *url.URL {
@@ -135,8 +148,11 @@ func (c *Context) ContentType() string {
 	return c.req.Header().Get(`Content-Type`)
 }
 
-// Session ..
+// Session return current session, session values loaded from SessionStore if server has one
 func (c *Context) Session() *Session {
+	if !c.sess.loaded && c.s.sessions != nil {
+		c.s.sessions.load(c)
+	}
 	return c.sess
 }
 
@@ -150,7 +166,7 @@ func (c *Context) RequiredParams(names string) (json.Object, error) {
 	jsResp := json.Object{}
 	for _, s := range split {
 		name := strings.TrimSpace(s)
-		if js.Has(name) {
+		if !js.Has(name) {
 			return nil, errors.New(`parameter not found: ` + name)
 		}
 		jsResp.Put(name, js.Get(name))
@@ -174,15 +190,11 @@ func (c *Context) RemoteIP() string {
 }
 
 func (c *Context) closeTx() {
-	if c.stdTx == nil {
-		return
-	}
 	if c.resp.err != nil {
-		c.stdTx.Rollback()
+		c.Rollback()
 	} else {
-		c.stdTx.Commit()
+		c.Commit()
 	}
-	c.stdTx = nil
 }
 
 func (c *Context) httpError(httpCode, statusCode int, msg string) error {
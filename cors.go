@@ -0,0 +1,167 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions cross-origin resource sharing policy
+type CORSOptions struct {
+	// AllowOrigins allowed origins, "*" for any origin (not allowed with AllowCredentials), wildcard (ex: https://*.example.com) or regex starting with ^
+	AllowOrigins []string
+	// AllowMethods default to methods having route matching request path
+	AllowMethods []string
+	// AllowHeaders default to headers requested by preflight request
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+type corsPolicy struct {
+	*originMatcher
+	opt CORSOptions
+}
+
+// originMatcher match origin exactly, any origin using "*", wildcard or regex starting with ^
+type originMatcher struct {
+	any     bool
+	origins map[string]struct{}
+	regexes []*regexp.Regexp
+}
+
+var errCORSAnyCredentials = errors.New(`CORS origin "*" not allowed with AllowCredentials`)
+
+// SetCORS set CORS policy of all routes, policy of group set using Group.SetCORS take precedence. Return error if origin regex invalid or "*" origin used with AllowCredentials, policy not changed.
+func (s *Server) SetCORS(opt CORSOptions) error {
+	return s.defGroup().SetCORS(opt)
+}
+
+// SetCORS set CORS policy of routes in group. Return error if origin regex invalid or "*" origin used with AllowCredentials, policy not changed.
+func (g *Group) SetCORS(opt CORSOptions) error {
+	m, e := newOriginMatcher(opt.AllowOrigins)
+	if e != nil {
+		return e
+	}
+	if m.any && opt.AllowCredentials {
+		// any site could make credentialed request, list origins explicitly instead
+		return errCORSAnyCredentials
+	}
+	if g.s.corsMap == nil {
+		g.s.corsMap = make(map[string]*corsPolicy)
+	}
+	g.s.corsMap[g.name] = &corsPolicy{originMatcher: m, opt: opt}
+	return nil
+}
+
+// newOriginMatcher return error if regex invalid
+func newOriginMatcher(origins []string) (*originMatcher, error) {
+	m := &originMatcher{origins: make(map[string]struct{})}
+	for _, origin := range origins {
+		switch {
+		case origin == `*`:
+			m.any = true
+		case strings.HasPrefix(origin, `^`):
+			regex, e := regexp.Compile(origin)
+			if e != nil {
+				return nil, fmt.Errorf(`invalid origin regex %s: %s`, origin, e)
+			}
+			m.regexes = append(m.regexes, regex)
+		case strings.Contains(origin, `*`):
+			pattern := strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, `[^/]*`)
+			regex, e := regexp.Compile(`^` + pattern + `$`)
+			if e != nil {
+				return nil, fmt.Errorf(`invalid origin %s: %s`, origin, e)
+			}
+			m.regexes = append(m.regexes, regex)
+		default:
+			m.origins[origin] = struct{}{}
+		}
+	}
+	return m, nil
+}
+
+func (m *originMatcher) allowed(origin string) bool {
+	if m.any {
+		return true
+	}
+	if _, ok := m.origins[origin]; ok {
+		return true
+	}
+	for _, regex := range m.regexes {
+		if regex.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) corsPolicy(group string) *corsPolicy {
+	if p, ok := s.corsMap[group]; ok {
+		return p
+	}
+	return s.corsMap[``]
+}
+
+// setCORSHeaders set CORS headers of request from allowed origin, return false if request has no origin or origin not allowed
+func (s *Server) setCORSHeaders(ctx *Context, group string) (*corsPolicy, bool) {
+	origin := ctx.req.Header().Get(`Origin`)
+	if origin == `` {
+		return nil, false
+	}
+	p := s.corsPolicy(group)
+	if p == nil {
+		return nil, false
+	}
+	header := ctx.resp.Header()
+	header.Add(`Vary`, `Origin`)
+	if !p.allowed(origin) {
+		return nil, false
+	}
+	if p.any {
+		header.Set(`Access-Control-Allow-Origin`, `*`)
+	} else {
+		header.Set(`Access-Control-Allow-Origin`, origin)
+	}
+	if p.opt.AllowCredentials {
+		header.Set(`Access-Control-Allow-Credentials`, `true`)
+	}
+	return p, true
+}
+
+// preflight answer CORS preflight request, before route executed
+func (s *Server) preflight(ctx *Context, t *routeTable, path string) bool {
+	method := ctx.req.Header().Get(`Access-Control-Request-Method`)
+	if ctx.Method() != MethodOptions || method == `` || len(s.corsMap) == 0 {
+		return false
+	}
+	route, _ := t.findRoute(method, path)
+	if route == nil {
+		return false
+	}
+	p, ok := s.setCORSHeaders(ctx, route.group)
+	if !ok {
+		return false
+	}
+	header := ctx.resp.Header()
+	methods := p.opt.AllowMethods
+	if len(methods) == 0 {
+		methods = t.allowedMethods(path)
+	}
+	header.Set(`Access-Control-Allow-Methods`, strings.Join(methods, `, `))
+	if len(p.opt.AllowHeaders) > 0 {
+		header.Set(`Access-Control-Allow-Headers`, strings.Join(p.opt.AllowHeaders, `, `))
+	} else if reqHeaders := ctx.req.Header().Get(`Access-Control-Request-Headers`); reqHeaders != `` {
+		header.Set(`Access-Control-Allow-Headers`, reqHeaders)
+	}
+	if p.opt.MaxAge > 0 {
+		header.Set(`Access-Control-Max-Age`, strconv.Itoa(int(p.opt.MaxAge.Seconds())))
+	}
+	ctx.resp.httpResp.SetStatusCode(StatusNoContent)
+	ctx.resp.stop = true
+	return true
+}
@@ -0,0 +1,76 @@
+package api
+
+import "testing"
+
+func TestSetCORSRejectAnyOriginWithCredentials(t *testing.T) {
+	s := newTestServer()
+	if e := s.SetCORS(CORSOptions{AllowOrigins: []string{`*`}, AllowCredentials: true}); e != errCORSAnyCredentials {
+		t.Errorf(`expected error for "*" origin with credentials, got %v`, e)
+	}
+	if s.corsPolicy(``) != nil {
+		t.Error(`policy set despite error`)
+	}
+}
+
+func TestSetCORSInvalidRegex(t *testing.T) {
+	if e := newTestServer().SetCORS(CORSOptions{AllowOrigins: []string{`^https://(app`}}); e == nil {
+		t.Error(`expected error for invalid origin regex`)
+	}
+}
+
+func TestWebsocketInvalidOriginRejectAll(t *testing.T) {
+	s := newTestServer()
+	ws := s.HandleWebsocket(`/ws`).AllowOrigins(`^https://(app`)
+	if ws.origins.allowed(`https://app.example.com`) {
+		t.Error(`origin allowed with invalid origin regex`)
+	}
+}
+
+func TestCORSCredentialsEchoAllowedOriginOnly(t *testing.T) {
+	s := newTestServer()
+	if e := s.SetCORS(CORSOptions{AllowOrigins: []string{`https://app.example.com`, `https://*.example.org`}, AllowCredentials: true}); e != nil {
+		t.Fatal(e)
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{`https://app.example.com`, true},
+		{`https://admin.example.org`, true},
+		{`https://evil.example.net`, false},
+		{`https://app.example.com.evil.net`, false},
+	}
+	for _, test := range tests {
+		ctx := newTestContext(t, s, MethodGet, `/users`, map[string]string{`Origin`: test.origin})
+		_, ok := s.setCORSHeaders(ctx, ``)
+		if ok != test.allowed {
+			t.Errorf(`origin %s allowed %v, expected %v`, test.origin, ok, test.allowed)
+		}
+		allowOrigin := responseHeader(ctx, `Access-Control-Allow-Origin`)
+		credentials := responseHeader(ctx, `Access-Control-Allow-Credentials`)
+		if test.allowed && (allowOrigin != test.origin || credentials != `true`) {
+			t.Errorf(`origin %s got Allow-Origin %q and Allow-Credentials %q`, test.origin, allowOrigin, credentials)
+		}
+		if !test.allowed && (allowOrigin != `` || credentials != ``) {
+			t.Errorf(`origin %s not allowed but got Allow-Origin %q and Allow-Credentials %q`, test.origin, allowOrigin, credentials)
+		}
+	}
+}
+
+func TestCORSAnyOriginWithoutCredentials(t *testing.T) {
+	s := newTestServer()
+	if e := s.SetCORS(CORSOptions{AllowOrigins: []string{`*`}}); e != nil {
+		t.Fatal(e)
+	}
+	ctx := newTestContext(t, s, MethodGet, `/users`, map[string]string{`Origin`: `https://any.example.com`})
+	if _, ok := s.setCORSHeaders(ctx, ``); !ok {
+		t.Fatal(`origin not allowed`)
+	}
+	if origin := responseHeader(ctx, `Access-Control-Allow-Origin`); origin != `*` {
+		t.Errorf(`expected Allow-Origin "*", got %q`, origin)
+	}
+	if credentials := responseHeader(ctx, `Access-Control-Allow-Credentials`); credentials != `` {
+		t.Errorf(`expected no Allow-Credentials, got %q`, credentials)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/eqto/dbm"
+)
+
+// database primary connection with its read replicas
+type database struct {
+	cn       *dbm.Connection
+	replicas []*dbm.Connection
+	next     uint32
+}
+
+// reader return next replica in round robin, primary if no replica
+func (d *database) reader() *dbm.Connection {
+	if len(d.replicas) == 0 {
+		return d.cn
+	}
+	idx := atomic.AddUint32(&d.next, 1)
+	return d.replicas[int(idx)%len(d.replicas)]
+}
+
+// AddDatabase add named connection, empty name replace default connection. Select and get query actions of TxReadOnly and TxNone route, or action using Action.Replica, executed on replicas when no transaction started on the database.
+func (s *Server) AddDatabase(name string, cn *dbm.Connection, replicas ...*dbm.Connection) {
+	s.dbLock.Lock()
+	defer s.dbLock.Unlock()
+	if s.databases == nil {
+		s.databases = make(map[string]*database)
+	}
+	if name == `` {
+		s.cn = cn
+	}
+	s.databases[name] = &database{cn: cn, replicas: replicas}
+}
+
+// AddReplica add read replica of named connection, empty name for default connection. Safe to call while serving.
+func (s *Server) AddReplica(name string, cn *dbm.Connection) error {
+	s.dbLock.Lock()
+	defer s.dbLock.Unlock()
+	db := s.databaseLocked(name)
+	if db == nil {
+		return fmt.Errorf(`database not found: %s`, name)
+	}
+	replicas := append(append([]*dbm.Connection{}, db.replicas...), cn)
+	s.databases[name] = &database{cn: db.cn, replicas: replicas}
+	return nil
+}
+
+// DatabaseNamed return named connection, nil if not exists
+func (s *Server) DatabaseNamed(name string) *dbm.Connection {
+	if db := s.database(name); db != nil {
+		return db.cn
+	}
+	return nil
+}
+
+func (s *Server) database(name string) *database {
+	s.dbLock.RLock()
+	defer s.dbLock.RUnlock()
+	return s.databaseLocked(name)
+}
+
+// databaseLocked return named database, caller must hold dbLock
+func (s *Server) databaseLocked(name string) *database {
+	if db, ok := s.databases[name]; ok {
+		return db
+	}
+	if name == `` && s.cn != nil {
+		return &database{cn: s.cn}
+	}
+	return nil
+}
+
+// DatabaseNamed return named connection
+func (c *Context) DatabaseNamed(name string) (*dbm.Connection, error) {
+	db := c.s.database(name)
+	if db == nil {
+		return nil, fmt.Errorf(`database not found: %s`, name)
+	}
+	return db.cn, nil
+}
+
+// TxNamed return transaction of named connection, started on first call and closed together with request transaction. Return error if route transaction policy is TxNone or TxReadOnly. Transactions of different connections are committed separately, not atomically.
+func (c *Context) TxNamed(name string) (*dbm.Tx, error) {
+	if c.tx.mode == TxReadOnly {
+		return nil, errTxReadOnly
+	}
+	return c.txNamed(name)
+}
+
+// txNamed return transaction of named connection for query actions, read-only transaction allowed since write query actions rejected before executed
+func (c *Context) txNamed(name string) (*dbm.Tx, error) {
+	if tx, ok := c.txs[name]; ok {
+		return tx, nil
+	}
+	if c.tx.mode == TxNone {
+		return nil, errTxDisabled
+	}
+	if c.parallel {
+		return nil, errTxParallel
+	}
+	db := c.s.database(name)
+	if db == nil {
+		if name == `` {
+			return nil, nil
+		}
+		return nil, fmt.Errorf(`database not found: %s`, name)
+	}
+	tx, e := c.beginTx(db.cn)
+	if e != nil { //db error
+		c.setErr(e)
+		return nil, c.StatusServiceUnavailable(`Service unavailable`)
+	}
+	if c.txs == nil {
+		c.txs = make(map[string]*dbm.Tx)
+	}
+	c.txs[name] = tx
+	return tx, nil
+}
+
+// runner return connection for query action: open transaction of database, replica when allowed and no transaction started, connection if route has no transaction or action is parallel, otherwise new transaction
+func (c *Context) runner(name string, replica bool) (queryRunner, error) {
+	if tx, ok := c.txs[name]; ok {
+		return tx, nil
+	}
+	db := c.s.database(name)
+	if db == nil {
+		return nil, fmt.Errorf(`database not found: %s`, name)
+	}
+	if replica && len(db.replicas) > 0 {
+		return db.reader(), nil
+	}
+	if c.tx.mode == TxNone || c.parallel {
+		return db.cn, nil
+	}
+	return c.txNamed(name)
+}
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eqto/dbm"
+	"github.com/eqto/dbm/stmt"
+)
+
+// testResult rows returned by testDB for a query
+type testResult struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+// testDB fake database recording executed statements, including BEGIN, COMMIT and ROLLBACK
+type testDB struct {
+	lock    sync.Mutex
+	queries []string
+	args    [][]interface{}
+	// handler return rows of query, nil result for no rows
+	handler func(query string, args []interface{}) (*testResult, error)
+}
+
+var (
+	testDBs   sync.Map
+	testDBSeq int32
+)
+
+func init() {
+	for _, name := range []string{`mysql`, `sqlserver`} {
+		sql.Register(`apitest-`+name, testSQLDriver{})
+		dbm.Register(`apitest-`+name, testDriver{name: name})
+	}
+}
+
+// newTestDB connect to fake database using dbm driver name mysql or sqlserver
+func newTestDB(t *testing.T, driverName string) (*dbm.Connection, *testDB) {
+	t.Helper()
+	db := &testDB{}
+	dsn := fmt.Sprintf(`db%d`, atomic.AddInt32(&testDBSeq, 1))
+	testDBs.Store(dsn, db)
+	cn, e := dbm.Connect(`apitest-`+driverName, ``, 0, ``, ``, dsn)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return cn, db
+}
+
+func (d *testDB) execute(query string, args []driver.NamedValue) (*testResult, error) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	d.lock.Lock()
+	d.queries = append(d.queries, query)
+	d.args = append(d.args, values)
+	handler := d.handler
+	d.lock.Unlock()
+	if handler == nil {
+		return nil, nil
+	}
+	return handler(query, values)
+}
+
+// executed return executed statements
+func (d *testDB) executed() []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return append([]string{}, d.queries...)
+}
+
+// last return last statement starting with prefix and its bound values
+func (d *testDB) last(prefix string) (string, []interface{}) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i := len(d.queries) - 1; i >= 0; i-- {
+		if strings.HasPrefix(d.queries[i], prefix) {
+			return d.queries[i], d.args[i]
+		}
+	}
+	return ``, nil
+}
+
+type testSQLDriver struct{}
+
+func (testSQLDriver) Open(dsn string) (driver.Conn, error) {
+	db, ok := testDBs.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf(`test database not found: %s`, dsn)
+	}
+	return &testConn{db: db.(*testDB)}, nil
+}
+
+type testConn struct {
+	db *testDB
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New(`prepare not supported`)
+}
+
+func (c *testConn) Close() error {
+	return nil
+}
+
+func (c *testConn) Begin() (driver.Tx, error) {
+	c.db.execute(`BEGIN`, nil)
+	return &testTx{db: c.db}, nil
+}
+
+func (c *testConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, e := c.db.execute(query, args)
+	if e != nil {
+		return nil, e
+	}
+	if res == nil {
+		res = &testResult{}
+	}
+	return &testRows{res: res}, nil
+}
+
+func (c *testConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, e := c.db.execute(query, args); e != nil {
+		return nil, e
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type testTx struct {
+	db *testDB
+}
+
+func (t *testTx) Commit() error {
+	t.db.execute(`COMMIT`, nil)
+	return nil
+}
+
+func (t *testTx) Rollback() error {
+	t.db.execute(`ROLLBACK`, nil)
+	return nil
+}
+
+type testRows struct {
+	res *testResult
+	idx int
+}
+
+func (r *testRows) Columns() []string {
+	return r.res.cols
+}
+
+func (r *testRows) Close() error {
+	return nil
+}
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.idx >= len(r.res.rows) {
+		return io.EOF
+	}
+	copy(dest, r.res.rows[r.idx])
+	r.idx++
+	return nil
+}
+
+// ColumnTypeScanType type of column value in first row, string if no rows
+func (r *testRows) ColumnTypeScanType(index int) reflect.Type {
+	if len(r.res.rows) > 0 && r.res.rows[0][index] != nil {
+		return reflect.TypeOf(r.res.rows[0][index])
+	}
+	return reflect.TypeOf(``)
+}
+
+// testDriver dbm driver rendering select statement like mysql driver
+type testDriver struct {
+	name string
+}
+
+func (d testDriver) Name() string {
+	return d.name
+}
+
+func (testDriver) DataSourceName(cfg dbm.Config) string {
+	return cfg.Name
+}
+
+func (testDriver) StatementString(s interface{}) string {
+	sel, ok := stmt.StatementOf(s).(*stmt.Select)
+	if !ok {
+		return ``
+	}
+	fields := stmt.FieldsOf(sel)
+	fieldStrs := []string{}
+	for i, name := range fields.Names() {
+		if alias := fields.AliasByIndex(i); alias != `` {
+			name += ` AS ` + alias
+		}
+		fieldStrs = append(fieldStrs, name)
+	}
+	tables := stmt.TablesOf(sel)
+	tableStrs := []string{}
+	for i, name := range tables.Names() {
+		if alias := tables.TableByIndex(i).Alias; alias != `` {
+			name += ` ` + alias
+		}
+		tableStrs = append(tableStrs, name)
+	}
+	sql := fmt.Sprintf(`SELECT %s FROM %s`, strings.Join(fieldStrs, `, `), strings.Join(tableStrs, `, `))
+	for i, where := range stmt.WheresOf(sel) {
+		switch {
+		case i == 0:
+			sql += ` WHERE `
+		case where.Or:
+			sql += ` OR `
+		default:
+			sql += ` AND `
+		}
+		sql += where.Condition
+	}
+	if orderBy := stmt.OrderByOf(sel); orderBy != `` {
+		sql += ` ORDER BY ` + orderBy
+	}
+	if offset, count := stmt.LimitOf(sel); count > 0 {
+		sql += fmt.Sprintf(` LIMIT %d, %d`, offset, count)
+	}
+	return sql
+}
+
+func (testDriver) IsDuplicate(e error) bool {
+	return false
+}
+
+func (testDriver) BuildContents(types []*sql.ColumnType) ([]interface{}, error) {
+	contents := make([]interface{}, len(types))
+	for i, typ := range types {
+		contents[i] = reflect.New(typ.ScanType()).Interface()
+	}
+	return contents, nil
+}
+
+func (testDriver) SanitizeParams(values []interface{}) []interface{} {
+	return values
+}
+
+func TestReplicaRoundRobin(t *testing.T) {
+	s := newTestServer()
+	primary, primaryDB := newTestDB(t, `mysql`)
+	replica1, replicaDB1 := newTestDB(t, `mysql`)
+	replica2, replicaDB2 := newTestDB(t, `mysql`)
+	s.AddDatabase(``, primary, replica1)
+	if e := s.AddReplica(``, replica2); e != nil {
+		t.Fatal(e)
+	}
+	if e := s.AddReplica(`reports`, replica2); e == nil {
+		t.Error(`expected error adding replica of unknown database`)
+	}
+	s.Get(`/books`).AddQueryAction(`SELECT * FROM books`, ``).Replica()
+	client := serveTest(t, s)
+
+	for i := 0; i < 4; i++ {
+		if resp := doTest(t, client, MethodGet, `/books`, nil); resp.StatusCode() != StatusOK {
+			t.Fatalf(`expected status 200, got %d: %s`, resp.StatusCode(), resp.Body())
+		}
+	}
+	if queries := primaryDB.executed(); len(queries) != 0 {
+		t.Errorf(`expected no query on primary, got %v`, queries)
+	}
+	if n1, n2 := len(replicaDB1.executed()), len(replicaDB2.executed()); n1 != 2 || n2 != 2 {
+		t.Errorf(`expected 2 queries on each replica, got %d and %d`, n1, n2)
+	}
+}
+
+func TestAddReplicaWhileServing(t *testing.T) {
+	s := newTestServer()
+	primary, _ := newTestDB(t, `mysql`)
+	s.AddDatabase(``, primary)
+	s.Get(`/books`).AddQueryAction(`SELECT * FROM books`, ``).Replica()
+	client := serveTest(t, s)
+
+	replicas := []*dbm.Connection{}
+	for i := 0; i < 20; i++ {
+		replica, _ := newTestDB(t, `mysql`)
+		replicas = append(replicas, replica)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, replica := range replicas {
+			s.AddReplica(``, replica)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		doTest(t, client, MethodGet, `/books`, nil)
+	}
+	<-done
+	if db := s.database(``); len(db.replicas) != 20 {
+		t.Errorf(`expected 20 replicas, got %d`, len(db.replicas))
+	}
+}
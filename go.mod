@@ -1,6 +1,6 @@
 module github.com/eqto/api-server
 
-go 1.16
+go 1.18
 
 require (
 	github.com/dgrr/websocket v0.1.1
@@ -9,3 +9,9 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/valyala/fasthttp v1.56.0
 )
+
+require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+)
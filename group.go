@@ -55,13 +55,75 @@ func (g *Group) Post(path string) *Route {
 	return g.getRoute(MethodPost, g.formatPath(path))
 }
 
+func (g *Group) Put(path string) *Route {
+	return g.getRoute(MethodPut, g.formatPath(path))
+}
+
+func (g *Group) PutAction(f func(*Context) error) *Route {
+	return g.action(MethodPut, f)
+}
+
+func (g *Group) PutSecureAction(f func(*Context) error) *Route {
+	return g.action(MethodPut, f).Secure()
+}
+
+func (g *Group) Patch(path string) *Route {
+	return g.getRoute(MethodPatch, g.formatPath(path))
+}
+
+func (g *Group) PatchAction(f func(*Context) error) *Route {
+	return g.action(MethodPatch, f)
+}
+
+func (g *Group) PatchSecureAction(f func(*Context) error) *Route {
+	return g.action(MethodPatch, f).Secure()
+}
+
+func (g *Group) Delete(path string) *Route {
+	return g.getRoute(MethodDelete, g.formatPath(path))
+}
+
+func (g *Group) DeleteAction(f func(*Context) error) *Route {
+	return g.action(MethodDelete, f)
+}
+
+func (g *Group) DeleteSecureAction(f func(*Context) error) *Route {
+	return g.action(MethodDelete, f).Secure()
+}
+
+func (g *Group) Head(path string) *Route {
+	return g.getRoute(MethodHead, g.formatPath(path))
+}
+
+func (g *Group) HeadAction(f func(*Context) error) *Route {
+	return g.action(MethodHead, f)
+}
+
+func (g *Group) HeadSecureAction(f func(*Context) error) *Route {
+	return g.action(MethodHead, f).Secure()
+}
+
+func (g *Group) Options(path string) *Route {
+	return g.getRoute(MethodOptions, g.formatPath(path))
+}
+
+func (g *Group) OptionsAction(f func(*Context) error) *Route {
+	return g.action(MethodOptions, f)
+}
+
+func (g *Group) OptionsSecureAction(f func(*Context) error) *Route {
+	return g.action(MethodOptions, f).Secure()
+}
+
+// HandleWebsocket add websocket route, each path has its own handlers, clients and options
 func (g *Group) HandleWebsocket(path string) *Websocket {
 	route := g.getRoute(MethodGet, g.formatPath(path))
-	route.isWs = true
-	if g.s.wsServ == nil {
-		g.s.wsServ = websocket.NewServer()
+	if route.ws == nil {
+		wsServ := websocket.NewServer()
+		g.s.wsServs = append(g.s.wsServs, wsServ)
+		route.ws = &Websocket{wsServ: wsServ, route: route}
 	}
-	return &Websocket{wsServ: g.s.wsServ}
+	return route.ws
 }
 
 func (g *Group) PostAction(f func(*Context) error) *Route {
@@ -92,19 +154,30 @@ func (g *Group) action(method string, f func(*Context) error) *Route {
 	return route
 }
 
+// getRoute return route of path, registered if not exists. Routes modified in place, so only allowed before serving since request handler read them without lock.
 func (g *Group) getRoute(method, path string) *Route {
-	switch method {
-	case MethodGet, MethodPost:
-	default:
-		return nil
+	g.s.tableMu.Lock()
+	defer g.s.tableMu.Unlock()
+	rt, ok := g.s.routes().routers[method]
+	if !ok {
+		g.s.logger.E(`unsupported method`, method)
+		return &Route{logger: g.s.logger}
 	}
 	if g.s.normalize {
-		path = normalizePath(path)
+		path = normalizePattern(path)
 	}
-	route, ok := g.s.routeMap[method][path]
-	if !ok {
-		route = &Route{logger: g.s.logger}
-		g.s.routeMap[method][path] = route
+	if g.s.tableServed {
+		g.s.logger.E(fmt.Sprintf(`unable to change route %s %s while serving, use LoadRoutes`, method, path))
+		return &Route{logger: g.s.logger}
+	}
+	route := rt.get(path)
+	if route == nil {
+		r, e := rt.add(path, &Route{logger: g.s.logger})
+		if e != nil {
+			g.s.logger.E(e)
+			return &Route{logger: g.s.logger}
+		}
+		route = r
 		g.s.logger.D(fmt.Sprintf(`Register route: %s %s`, method, path))
 	}
 	route.UseGroup(g.name)
@@ -112,7 +185,23 @@ func (g *Group) getRoute(method, path string) *Route {
 }
 
 func (g *Group) RemoveRoute(method, path string) {
-	delete(g.s.routeMap[method], path)
+	g.s.updateRoutes(func(t *routeTable) error {
+		if rt, ok := t.routers[method]; ok {
+			rt.remove(path)
+		}
+		return nil
+	})
+}
+
+// normalizePattern normalize static segments only, parameter and catch-all segments are kept as is
+func normalizePattern(path string) string {
+	segments := splitPath(path)
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, `:`) && !strings.HasPrefix(seg, `*`) {
+			segments[i] = normalizePath(seg)
+		}
+	}
+	return `/` + strings.Join(segments, `/`)
 }
 
 func normalizePath(path string) string {
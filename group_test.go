@@ -0,0 +1,60 @@
+package api
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGetRouteReturnRegistered(t *testing.T) {
+	s := newTestServer()
+	route := s.Get(`/a`)
+	if again := s.Get(`/a`); again != route {
+		t.Fatal(`registered route not returned`)
+	}
+	route.AddAction(func(*Context) error { return nil })
+	if registered, _ := s.routes().findRoute(MethodGet, `/a`); registered != route || len(registered.action) != 1 {
+		t.Error(`action added to earlier route handle lost`)
+	}
+
+	ws := s.HandleWebsocket(`/ws`).Secure()
+	if again := s.HandleWebsocket(`/ws`); again != ws {
+		t.Fatal(`registered websocket not returned`)
+	}
+	if registered, _ := s.routes().findRoute(MethodGet, `/ws`); registered.ws != ws || !registered.secure {
+		t.Error(`websocket option set on earlier handle lost`)
+	}
+}
+
+func TestGetRouteWhileServing(t *testing.T) {
+	s := newTestServer()
+	calls := 0
+	s.Get(`/a`).AddAction(func(*Context) error {
+		calls++
+		return nil
+	})
+	client := serveTest(t, s)
+	doTest(t, client, MethodGet, `/a`, nil)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			s.Get(`/a`).AddAction(func(*Context) error { return nil })
+			s.Get(`/b`)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		doTest(t, client, MethodGet, `/a`, nil)
+	}
+	wg.Wait()
+	if calls != 21 {
+		t.Errorf(`expected 21 calls, got %d`, calls)
+	}
+	if route, _ := s.routes().findRoute(MethodGet, `/a`); len(route.action) != 1 {
+		t.Errorf(`route changed while serving, got %d actions`, len(route.action))
+	}
+	if route, _ := s.routes().findRoute(MethodGet, `/b`); route != nil {
+		t.Error(`route registered while serving`)
+	}
+}
@@ -56,6 +56,16 @@ func (r *ResponseHeader) SetCookie(key, value string, expireIn time.Duration) {
 	r.httpHeader.SetCookie(ck)
 }
 
+// DelCookie instruct client to remove cookie that set using SetCookie
+func (r *ResponseHeader) DelCookie(key string) {
+	ck := &fasthttp.Cookie{}
+	ck.SetKey(key)
+	ck.SetExpire(fasthttp.CookieExpireDelete)
+	ck.SetHTTPOnly(true)
+	ck.SetPath(`/`)
+	r.httpHeader.SetCookie(ck)
+}
+
 func (r *ResponseHeader) Add(key, value string) {
 	r.httpHeader.Add(key, value)
 }
@@ -0,0 +1,421 @@
+package api
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	JWTAlgorithmHS256 = `HS256`
+	JWTAlgorithmRS256 = `RS256`
+	JWTAlgorithmES256 = `ES256`
+)
+
+// JWTKey key used to sign or verify token. Key type depends on algorithm:
+// HS256 []byte, RS256 *rsa.PrivateKey or *rsa.PublicKey, ES256 *ecdsa.PrivateKey or *ecdsa.PublicKey.
+// Private keys can be used for both signing and verifying.
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	Key       interface{}
+}
+
+// JWTKeySet collection of keys identified by key id (kid), safe to modify while server running for key rotation.
+type JWTKeySet struct {
+	lock sync.RWMutex
+	keys []JWTKey
+}
+
+// Add add or replace key with the same id
+func (k *JWTKeySet) Add(key JWTKey) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	for i, existing := range k.keys {
+		if existing.ID == key.ID {
+			k.keys[i] = key
+			return
+		}
+	}
+	k.keys = append(k.keys, key)
+}
+
+func (k *JWTKeySet) Remove(id string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	for i, existing := range k.keys {
+		if existing.ID == id {
+			k.keys = append(k.keys[:i], k.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// LoadFile replace all keys with public keys from JWKS file, call it again to rotate keys
+func (k *JWTKeySet) LoadFile(filename string) error {
+	data, e := ioutil.ReadFile(filename)
+	if e != nil {
+		return e
+	}
+	keys, e := parseJWKS(data)
+	if e != nil {
+		return e
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.keys = keys
+	return nil
+}
+
+// find return keys matching kid and algorithm, empty kid matching all keys with the same algorithm
+func (k *JWTKeySet) find(kid, alg string) []JWTKey {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	keys := []JWTKey{}
+	for _, key := range k.keys {
+		if key.Algorithm == alg && (kid == `` || key.ID == kid) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func NewJWTKeySet(keys ...JWTKey) *JWTKeySet {
+	return &JWTKeySet{keys: keys}
+}
+
+// JWTOptions configuration for JWT middleware
+type JWTOptions struct {
+	Keys *JWTKeySet
+	// Issuer if not empty, token iss claim must be equal
+	Issuer string
+	// Audience if not empty, token aud claim must contain it
+	Audience string
+	// ClockSkew tolerance when validating exp and nbf claims
+	ClockSkew time.Duration
+	// ClaimMap map claim name to session key. If nil all claims except user_id copied to session with the same name. Sub claim always copied as user_id unless ClaimMap map other claim to user_id.
+	ClaimMap map[string]string
+}
+
+// JWTMiddleware validate bearer token from Authorization header and put its claims to Context.Session(). Claims only available in current request, they take precedence over stored session values and never saved to session store.
+func JWTMiddleware(opt JWTOptions) func(*Context) error {
+	return func(ctx *Context) error {
+		auth := ctx.req.Header().Get(`Authorization`)
+		if len(auth) < 7 || !strings.EqualFold(auth[:7], `Bearer `) {
+			return errors.New(`missing bearer token`)
+		}
+		claims, e := VerifyJWT(strings.TrimSpace(auth[7:]), opt)
+		if e != nil {
+			return e
+		}
+		sess := ctx.Session()
+		if sub, ok := claims[`sub`]; ok {
+			sess.set(`user_id`, sub)
+		}
+		if opt.ClaimMap == nil {
+			for key, val := range claims {
+				if key != `user_id` {
+					sess.set(key, val)
+				}
+			}
+		} else {
+			for claim, key := range opt.ClaimMap {
+				if val, ok := claims[claim]; ok {
+					sess.set(key, val)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// AddJWTMiddleware add secure middleware validating bearer token
+func (g *Group) AddJWTMiddleware(opt JWTOptions) Middleware {
+	return g.AddMiddleware(JWTMiddleware(opt)).Secure()
+}
+
+// AddJWTMiddleware add secure middleware validating bearer token
+func (s *Server) AddJWTMiddleware(opt JWTOptions) Middleware {
+	return s.defGroup().AddJWTMiddleware(opt)
+}
+
+// VerifyJWT verify token signature and registered claims, return all token claims
+func VerifyJWT(token string, opt JWTOptions) (map[string]interface{}, error) {
+	if opt.Keys == nil {
+		return nil, errors.New(`jwt key set not available`)
+	}
+	parts := strings.Split(token, `.`)
+	if len(parts) != 3 {
+		return nil, errors.New(`invalid token format`)
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if e := decodeJWTPart(parts[0], &header); e != nil {
+		return nil, e
+	}
+	sig, e := base64.RawURLEncoding.DecodeString(parts[2])
+	if e != nil {
+		return nil, errors.New(`invalid token signature`)
+	}
+	signed := []byte(parts[0] + `.` + parts[1])
+	keys := opt.Keys.find(header.Kid, header.Alg)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf(`no key found for algorithm %s`, header.Alg)
+	}
+	verified := false
+	for _, key := range keys {
+		if verifyJWTSignature(key, signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New(`invalid token signature`)
+	}
+
+	claims := make(map[string]interface{})
+	if e := decodeJWTPart(parts[1], &claims); e != nil {
+		return nil, e
+	}
+	now := time.Now()
+	if exp, ok := claims[`exp`]; ok {
+		if t, ok := jwtTime(exp); !ok || now.After(t.Add(opt.ClockSkew)) {
+			return nil, errors.New(`token expired`)
+		}
+	}
+	if nbf, ok := claims[`nbf`]; ok {
+		if t, ok := jwtTime(nbf); !ok || now.Add(opt.ClockSkew).Before(t) {
+			return nil, errors.New(`token not valid yet`)
+		}
+	}
+	if opt.Issuer != `` {
+		if iss, _ := claims[`iss`].(string); iss != opt.Issuer {
+			return nil, errors.New(`invalid token issuer`)
+		}
+	}
+	if opt.Audience != `` && !jwtHasAudience(claims[`aud`], opt.Audience) {
+		return nil, errors.New(`invalid token audience`)
+	}
+	return claims, nil
+}
+
+// SignJWT create signed token, iat claim is added and exp claim is added if ttl > 0. Use it to issue token from login endpoint.
+func SignJWT(key JWTKey, claims map[string]interface{}, ttl time.Duration) (string, error) {
+	payload := make(map[string]interface{}, len(claims)+2)
+	for k, v := range claims {
+		payload[k] = v
+	}
+	now := time.Now()
+	payload[`iat`] = now.Unix()
+	if ttl > 0 {
+		payload[`exp`] = now.Add(ttl).Unix()
+	}
+	header := map[string]string{`alg`: key.Algorithm, `typ`: `JWT`}
+	if key.ID != `` {
+		header[`kid`] = key.ID
+	}
+	headerJs, e := json.Marshal(header)
+	if e != nil {
+		return ``, e
+	}
+	payloadJs, e := json.Marshal(payload)
+	if e != nil {
+		return ``, e
+	}
+	signed := base64.RawURLEncoding.EncodeToString(headerJs) + `.` + base64.RawURLEncoding.EncodeToString(payloadJs)
+	sig, e := signJWT(key, []byte(signed))
+	if e != nil {
+		return ``, e
+	}
+	return signed + `.` + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func signJWT(key JWTKey, data []byte) ([]byte, error) {
+	switch key.Algorithm {
+	case JWTAlgorithmHS256:
+		secret, ok := key.Key.([]byte)
+		if !ok {
+			return nil, errors.New(`HS256 key must be []byte`)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case JWTAlgorithmRS256:
+		priv, ok := key.Key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New(`RS256 signing key must be *rsa.PrivateKey`)
+		}
+		hash := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, hash[:])
+	case JWTAlgorithmES256:
+		priv, ok := key.Key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New(`ES256 signing key must be *ecdsa.PrivateKey`)
+		}
+		hash := sha256.Sum256(data)
+		r, s, e := ecdsa.Sign(rand.Reader, priv, hash[:])
+		if e != nil {
+			return nil, e
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	}
+	return nil, fmt.Errorf(`unsupported algorithm %s`, key.Algorithm)
+}
+
+func verifyJWTSignature(key JWTKey, data, sig []byte) bool {
+	hash := sha256.Sum256(data)
+	switch key.Algorithm {
+	case JWTAlgorithmHS256:
+		secret, ok := key.Key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(data)
+		return hmac.Equal(mac.Sum(nil), sig)
+	case JWTAlgorithmRS256:
+		var pub *rsa.PublicKey
+		switch k := key.Key.(type) {
+		case *rsa.PublicKey:
+			pub = k
+		case *rsa.PrivateKey:
+			pub = &k.PublicKey
+		default:
+			return false
+		}
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], sig) == nil
+	case JWTAlgorithmES256:
+		var pub *ecdsa.PublicKey
+		switch k := key.Key.(type) {
+		case *ecdsa.PublicKey:
+			pub = k
+		case *ecdsa.PrivateKey:
+			pub = &k.PublicKey
+		default:
+			return false
+		}
+		if len(sig) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(pub, hash[:], r, s)
+	}
+	return false
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, e := base64.RawURLEncoding.DecodeString(part)
+	if e != nil {
+		return errors.New(`invalid token encoding`)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if e := dec.Decode(v); e != nil {
+		return errors.New(`invalid token encoding`)
+	}
+	return nil
+}
+
+func jwtTime(val interface{}) (time.Time, bool) {
+	num, ok := val.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, e := num.Float64()
+	if e != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func jwtHasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a, ok := a.(string); ok && a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseJWKS(data []byte) ([]JWTKey, error) {
+	jwks := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}{}
+	if e := json.Unmarshal(data, &jwks); e != nil {
+		return nil, e
+	}
+	keys := []JWTKey{}
+	for _, jwk := range jwks.Keys {
+		switch jwk.Kty {
+		case `RSA`:
+			n, e := base64.RawURLEncoding.DecodeString(jwk.N)
+			if e != nil {
+				return nil, fmt.Errorf(`invalid RSA key %s: %s`, jwk.Kid, e)
+			}
+			exp, e := base64.RawURLEncoding.DecodeString(jwk.E)
+			if e != nil {
+				return nil, fmt.Errorf(`invalid RSA key %s: %s`, jwk.Kid, e)
+			}
+			pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(exp).Int64())}
+			keys = append(keys, JWTKey{ID: jwk.Kid, Algorithm: JWTAlgorithmRS256, Key: pub})
+		case `EC`:
+			if jwk.Crv != `P-256` {
+				return nil, fmt.Errorf(`unsupported EC curve %s for key %s`, jwk.Crv, jwk.Kid)
+			}
+			x, e := base64.RawURLEncoding.DecodeString(jwk.X)
+			if e != nil {
+				return nil, fmt.Errorf(`invalid EC key %s: %s`, jwk.Kid, e)
+			}
+			y, e := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if e != nil {
+				return nil, fmt.Errorf(`invalid EC key %s: %s`, jwk.Kid, e)
+			}
+			pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			keys = append(keys, JWTKey{ID: jwk.Kid, Algorithm: JWTAlgorithmES256, Key: pub})
+		case `oct`:
+			k, e := base64.RawURLEncoding.DecodeString(jwk.K)
+			if e != nil {
+				return nil, fmt.Errorf(`invalid oct key %s: %s`, jwk.Kid, e)
+			}
+			keys = append(keys, JWTKey{ID: jwk.Kid, Algorithm: JWTAlgorithmHS256, Key: k})
+		default:
+			return nil, fmt.Errorf(`unsupported key type %s`, jwk.Kty)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func testJWTContext(t *testing.T, claims map[string]interface{}, claimMap map[string]string) (*Context, error) {
+	t.Helper()
+	key := JWTKey{ID: `k1`, Algorithm: JWTAlgorithmHS256, Key: []byte(`secret`)}
+	token, e := SignJWT(key, claims, time.Minute)
+	if e != nil {
+		t.Fatal(e)
+	}
+	s := newTestServer()
+	ctx := newTestContext(t, s, MethodGet, `/me`, map[string]string{`Authorization`: `Bearer ` + token})
+	ctx.Session().Put(`user_id`, `stored-user`)
+	return ctx, JWTMiddleware(JWTOptions{Keys: NewJWTKeySet(key), ClaimMap: claimMap})(ctx)
+}
+
+func TestJWTSubOverrideStoredUserID(t *testing.T) {
+	ctx, e := testJWTContext(t, map[string]interface{}{`sub`: `token-user`, `role`: `admin`}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	sess := ctx.Session()
+	if userID := sess.GetString(`user_id`); userID != `token-user` {
+		t.Errorf(`expected user_id from sub, got %q`, userID)
+	}
+	if role := sess.GetString(`role`); role != `admin` {
+		t.Errorf(`expected role claim, got %q`, role)
+	}
+}
+
+func TestJWTSubOverrideUserIDClaim(t *testing.T) {
+	ctx, e := testJWTContext(t, map[string]interface{}{`sub`: `token-user`, `user_id`: `other-user`}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if userID := ctx.Session().GetString(`user_id`); userID != `token-user` {
+		t.Errorf(`expected user_id from sub, got %q`, userID)
+	}
+}
+
+func TestJWTClaimsNotPersisted(t *testing.T) {
+	ctx, e := testJWTContext(t, map[string]interface{}{`sub`: `token-user`, `role`: `admin`}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	sess := ctx.Session()
+	sess.Put(`theme`, `dark`)
+	if _, ok := sess.val[`role`]; ok {
+		t.Error(`role claim saved to session store`)
+	}
+	if userID := sess.val[`user_id`]; userID != `stored-user` {
+		t.Errorf(`stored user_id changed to %v`, userID)
+	}
+}
+
+func TestJWTClaimMap(t *testing.T) {
+	ctx, e := testJWTContext(t, map[string]interface{}{`sub`: `token-user`, `role`: `admin`}, map[string]string{`role`: `user_role`})
+	if e != nil {
+		t.Fatal(e)
+	}
+	sess := ctx.Session()
+	if userID := sess.GetString(`user_id`); userID != `token-user` {
+		t.Errorf(`expected user_id from sub, got %q`, userID)
+	}
+	if role := sess.GetString(`user_role`); role != `admin` {
+		t.Errorf(`expected mapped role claim, got %q`, role)
+	}
+	if sess.Has(`role`) {
+		t.Error(`unmapped claim copied to session`)
+	}
+}
+
+func TestJWTRejectInvalidToken(t *testing.T) {
+	s := newTestServer()
+	key := JWTKey{Algorithm: JWTAlgorithmHS256, Key: []byte(`secret`)}
+	token, e := SignJWT(JWTKey{Algorithm: JWTAlgorithmHS256, Key: []byte(`other`)}, map[string]interface{}{`sub`: `token-user`}, time.Minute)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ctx := newTestContext(t, s, MethodGet, `/me`, map[string]string{`Authorization`: `Bearer ` + token})
+	if e := JWTMiddleware(JWTOptions{Keys: NewJWTKeySet(key)})(ctx); e == nil {
+		t.Fatal(`token signed with other key accepted`)
+	}
+	if ctx.Session().Has(`user_id`) {
+		t.Error(`user_id set from rejected token`)
+	}
+}
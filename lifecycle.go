@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/eqto/dbm"
+)
+
+// OnStart add func called before server start accepting connections, server not started if func return error
+func (s *Server) OnStart(fn func() error) {
+	s.onStart = append(s.onStart, fn)
+}
+
+// OnShutdown add func called on shutdown after in-flight requests drained and before databases closed
+func (s *Server) OnShutdown(fn func(ctx context.Context) error) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Ready return true if server started and not shutting down
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// HandleReadiness add GET route responding status 200 when server ready and 503 when shutting down
+func (s *Server) HandleReadiness(path string) *Route {
+	route := s.Get(path)
+	route.AddAction(func(ctx *Context) error {
+		if !s.Ready() {
+			return ctx.StatusServiceUnavailable(`server not ready`)
+		}
+		return nil
+	})
+	return route
+}
+
+// ShutdownContext shutdown gracefully: flip readiness to not ready, wait drain delay, stop accepting connections, close websocket clients, wait in-flight requests until ctx done, close remaining streams, call OnShutdown funcs, then close databases. Return first error, all steps executed regardless.
+func (s *Server) ShutdownContext(ctx context.Context) error {
+	atomic.StoreInt32(&s.ready, 0)
+	if s.drainDelay > 0 {
+		select {
+		case <-time.After(s.drainDelay):
+		case <-ctx.Done():
+		}
+	}
+	var err error
+	setErr := func(e error) {
+		if e != nil {
+			s.logger.W(`shutdown:`, e)
+			if err == nil {
+				err = e
+			}
+		}
+	}
+	// no client upgraded after listener closed, so every websocket client receive close frame
+	if s.ln != nil {
+		setErr(s.ln.Close())
+	}
+	for _, wsServ := range s.wsServs {
+		wsServ.CloseAll(`server shutdown`)
+	}
+	setErr(s.shutdownServer(ctx))
+	if s.redirectServ != nil {
+		setErr(s.redirectServ.ShutdownWithContext(ctx))
+		s.redirectServ = nil
+	}
+	s.closeStreams()
+	for _, fn := range s.onShutdown {
+		setErr(fn(ctx))
+	}
+	for _, cn := range s.connections() {
+		setErr(cn.Close())
+	}
+	return err
+}
+
+// ShutdownOnSignal block until one of signals received, default to SIGINT and SIGTERM, then shutdown gracefully waiting in-flight requests at most timeout
+func (s *Server) ShutdownOnSignal(timeout time.Duration, signals ...os.Signal) error {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+	sig := <-ch
+	s.logger.I(`signal received, shutting down:`, sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.ShutdownContext(ctx)
+}
+
+// shutdownServer stop accepting connections and wait in-flight requests until ctx done
+func (s *Server) shutdownServer(ctx context.Context) error {
+	if s.serv != nil {
+		if e := s.serv.ShutdownWithContext(ctx); e != nil {
+			return e
+		}
+	}
+	s.serv = nil
+	return nil
+}
+
+// shutdownListener listener closed by ShutdownContext before fasthttp shutdown close it again, only the first Close close underlying listener
+type shutdownListener struct {
+	net.Listener
+	closed int32
+}
+
+func (l *shutdownListener) Close() error {
+	if !atomic.CompareAndSwapInt32(&l.closed, 0, 1) {
+		return nil
+	}
+	return l.Listener.Close()
+}
+
+func (s *Server) addStream(sw *streamWriter) {
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+	if s.streams == nil {
+		s.streams = make(map[*streamWriter]struct{})
+	}
+	s.streams[sw] = struct{}{}
+}
+
+func (s *Server) removeStream(sw *streamWriter) {
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+	delete(s.streams, sw)
+}
+
+// closeStreams close streams still open after drained, further writes return error
+func (s *Server) closeStreams() {
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+	for sw := range s.streams {
+		sw.Close()
+	}
+}
+
+// connections return distinct database connections including replicas
+func (s *Server) connections() []*dbm.Connection {
+	cns := []*dbm.Connection{}
+	seen := map[*dbm.Connection]struct{}{}
+	add := func(cn *dbm.Connection) {
+		if _, ok := seen[cn]; !ok && cn != nil {
+			seen[cn] = struct{}{}
+			cns = append(cns, cn)
+		}
+	}
+	s.dbLock.RLock()
+	defer s.dbLock.RUnlock()
+	add(s.cn)
+	for _, db := range s.databases {
+		add(db.cn)
+		for _, replica := range db.replicas {
+			add(replica)
+		}
+	}
+	return cns
+}
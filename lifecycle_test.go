@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dgrr "github.com/dgrr/websocket"
+	"github.com/eqto/api-server/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// newLifecycleServer server with route /slow taking 200ms and websocket route /ws, value sent to started when /slow request started and to accepted when websocket client accepted, finished set to 1 when /slow action returned
+func newLifecycleServer(t *testing.T) (s *Server, started, accepted chan struct{}, finished *int32) {
+	t.Helper()
+	s = newTestServer()
+	started = make(chan struct{}, 1)
+	accepted = make(chan struct{}, 1)
+	finished = new(int32)
+	s.Get(`/slow`).AddAction(func(ctx *Context) error {
+		started <- struct{}{}
+		time.Sleep(200 * time.Millisecond)
+		atomic.StoreInt32(finished, 1)
+		return nil
+	})
+	s.HandleWebsocket(`/ws`).OnAccept(func(*websocket.Client) { accepted <- struct{}{} })
+	return s, started, accepted, finished
+}
+
+// dialWebsocket connect websocket client to path and wait until accepted
+func dialWebsocket(t *testing.T, ln *fasthttputil.InmemoryListener, path string, accepted chan struct{}) *dgrr.Client {
+	t.Helper()
+	conn, e := ln.Dial()
+	if e != nil {
+		t.Fatal(e)
+	}
+	c, e := dgrr.MakeClient(conn, `http://test`+path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal(`websocket client not accepted`)
+	}
+	return c
+}
+
+// requestSlow request /slow in background, status written after response received
+func requestSlow(ln *fasthttputil.InmemoryListener) (*int32, chan struct{}) {
+	status := new(int32)
+	done := make(chan struct{})
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+	go func() {
+		defer close(done)
+		req, resp := &fasthttp.Request{}, &fasthttp.Response{}
+		req.SetRequestURI(`http://test/slow`)
+		if e := client.Do(req, resp); e == nil {
+			atomic.StoreInt32(status, int32(resp.StatusCode()))
+		}
+	}()
+	return status, done
+}
+
+func TestShutdownContext(t *testing.T) {
+	s, started, accepted, finished := newLifecycleServer(t)
+	cn, _ := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	ln := serveTestListener(t, s)
+	wsClient := dialWebsocket(t, ln, `/ws`, accepted)
+
+	// listenerOpen whether new connection accepted when close frame received
+	listenerOpen := make(chan bool, 1)
+	go func() {
+		fr := dgrr.AcquireFrame()
+		defer dgrr.ReleaseFrame(fr)
+		for {
+			if _, e := wsClient.ReadFrame(fr); e != nil {
+				return
+			}
+			if fr.IsClose() {
+				conn, e := ln.Dial()
+				if e == nil {
+					conn.Close()
+				}
+				listenerOpen <- e == nil
+				return
+			}
+		}
+	}()
+
+	slowStatus, slowDone := requestSlow(ln)
+	<-started
+	var hookFinished int32
+	var hookErr error
+	s.OnShutdown(func(context.Context) error {
+		hookFinished = atomic.LoadInt32(finished)
+		_, hookErr = cn.Exec(`DELETE FROM sessions`)
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if e := s.ShutdownContext(ctx); e != nil {
+		t.Fatal(e)
+	}
+	<-slowDone
+
+	if s.Ready() {
+		t.Error(`server ready after shutdown`)
+	}
+	select {
+	case open := <-listenerOpen:
+		if open {
+			t.Error(`websocket client closed before listener`)
+		}
+	case <-time.After(time.Second):
+		t.Error(`websocket client not received close frame`)
+	}
+	if status := atomic.LoadInt32(slowStatus); status != StatusOK {
+		t.Errorf(`expected in-flight request finished with status 200, got %d`, status)
+	}
+	if hookFinished != 1 {
+		t.Error(`OnShutdown called before in-flight request finished`)
+	}
+	if hookErr != nil {
+		t.Errorf(`database closed before OnShutdown: %s`, hookErr)
+	}
+	if _, e := cn.Exec(`DELETE FROM sessions`); e == nil {
+		t.Error(`database not closed after shutdown`)
+	}
+}
+
+func TestShutdownKeepResources(t *testing.T) {
+	s, started, accepted, _ := newLifecycleServer(t)
+	cn, _ := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	hookCalled := false
+	s.OnShutdown(func(context.Context) error {
+		hookCalled = true
+		return nil
+	})
+	ln := serveTestListener(t, s)
+	wsClient := dialWebsocket(t, ln, `/ws`, accepted)
+
+	slowStatus, slowDone := requestSlow(ln)
+	<-started
+	if e := s.Shutdown(); e != nil {
+		t.Fatal(e)
+	}
+	<-slowDone
+
+	if status := atomic.LoadInt32(slowStatus); status != StatusOK {
+		t.Errorf(`expected in-flight request finished with status 200, got %d`, status)
+	}
+	if s.Ready() {
+		t.Error(`server ready after shutdown`)
+	}
+	if hookCalled {
+		t.Error(`OnShutdown called by Shutdown`)
+	}
+	if _, e := cn.Exec(`DELETE FROM sessions`); e != nil {
+		t.Errorf(`database closed by Shutdown: %s`, e)
+	}
+	if _, e := wsClient.Write([]byte(`ping`)); e != nil {
+		t.Errorf(`websocket client closed by Shutdown: %s`, e)
+	}
+}
@@ -0,0 +1,602 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LoadError error of route definition in file
+type LoadError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf(`%s:%d: %s`, e.File, e.Line, e.Message)
+}
+
+// LoadErrors all errors found while loading route definitions
+type LoadErrors []*LoadError
+
+func (e LoadErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+type routeConfig struct {
+	Method   string         `json:"method"`
+	Path     string         `json:"path"`
+	Group    string         `json:"group"`
+	Secure   bool           `json:"secure"`
+	Summary  string         `json:"summary"`
+	Database string         `json:"database"`
+	Cache    string         `json:"cache"`
+	Actions  []actionConfig `json:"actions"`
+
+	file string
+	line int
+}
+
+type actionConfig struct {
+	Query    string `json:"query"`
+	Params   string `json:"params"`
+	AssignTo string `json:"assign_to"`
+	Database string `json:"database"`
+	If       string `json:"if"`
+	ElseIf   string `json:"else_if"`
+	Else     bool   `json:"else"`
+	Parallel bool   `json:"parallel"`
+	Replica  bool   `json:"replica"`
+
+	Cast      map[string]string `json:"cast"`
+	Rename    map[string]string `json:"rename"`
+	Omit      []string          `json:"omit"`
+	Nest      []string          `json:"nest"`
+	NestArray []string          `json:"nest_array"`
+	GroupBy   string            `json:"group_by"`
+	AttachTo  *attachConfig     `json:"attach_to"`
+
+	line int
+}
+
+type attachConfig struct {
+	Parent    string `json:"parent"`
+	Field     string `json:"field"`
+	ParentKey string `json:"parent_key"`
+	ChildKey  string `json:"child_key"`
+}
+
+type fileConfig struct {
+	Path       string `json:"path"`
+	Dest       string `json:"dest"`
+	RedirectTo string `json:"redirect_to"`
+
+	file string
+	line int
+}
+
+// routeSet route and static file definitions parsed from files
+type routeSet struct {
+	routes []*routeConfig
+	files  []*fileConfig
+}
+
+// loadedRoutes keys of routes and static files registered from a routeSet, used to replace them on reload
+type loadedRoutes struct {
+	routes map[string]struct{}
+	files  map[string]struct{}
+}
+
+// LoadRoutes load route definitions from JSON or annotated SQL files, directory loads all .json and .sql files inside it. No route registered if any file contains error, all errors returned as LoadErrors.
+//
+// JSON file, files property register static files like FileRoute:
+//
+//	{"routes": [
+//	  {"method": "GET", "path": "/users/:id", "group": "admin", "secure": true,
+//	   "actions": [{"query": "SELECT * FROM users WHERE id = ? LIMIT 1", "params": ":id:int", "assign_to": "user"}]}
+//	],
+//	"files": [{"path": "/", "dest": "./public", "redirect_to": "index.html"}]}
+//
+// Action may have "if", "else_if", "else" and "parallel" properties for pipeline, see Action.If, Action.ElseIf, Action.Else and Action.Parallel.
+// Action "replica" property (@replica annotation) read on replica, see Action.Replica.
+// Result shaping properties: "cast" and "rename" (object of column to value), "omit", "nest" and "nest_array" (array), "group_by" and
+// "attach_to" ({"parent": "$orders", "field": "items", "parent_key": "id", "child_key": "order_id"}), see Action.Cast and others.
+//
+// Route "cache" property is cache duration, ex: "60s", see Route.Cache.
+//
+// SQL file, annotations other than @route, @group, @secure, @summary and @cache apply to the next statement, statement ends with semicolon.
+// Shaping annotations are @cast COLUMN TYPE, @rename COLUMN NAME, @omit COLUMNS, @nest PREFIX, @nestarray PREFIX, @groupby COLUMN and @attach PARENT FIELD PARENT_KEY CHILD_KEY:
+//
+//	-- @route GET /users/:id
+//	-- @group admin
+//	-- @secure
+//	-- @params :id:int
+//	-- @assign user
+//	SELECT * FROM users WHERE id = ? LIMIT 1;
+func (s *Server) LoadRoutes(paths ...string) error {
+	set, e := parseRouteFiles(paths)
+	if e != nil {
+		return e
+	}
+	_, e = s.applyRoutes(nil, set)
+	return e
+}
+
+// applyRoutes replace routes registered from prev with routes of set in a single swap, current routes kept if any route conflicts
+func (s *Server) applyRoutes(prev *loadedRoutes, set *routeSet) (*loadedRoutes, error) {
+	loaded := &loadedRoutes{routes: make(map[string]struct{}), files: make(map[string]struct{})}
+	e := s.updateRoutes(func(t *routeTable) error {
+		if prev != nil {
+			for key := range prev.routes {
+				method, path, _ := strings.Cut(key, ` `)
+				t.routers[method].remove(path)
+			}
+			for path := range prev.files {
+				t.removeFile(path)
+			}
+		}
+		errs := LoadErrors{}
+		for _, cfg := range set.routes {
+			key, e := s.addRoute(t, cfg)
+			if e != nil {
+				errs = append(errs, &LoadError{cfg.file, cfg.line, e.Error()})
+				continue
+			}
+			loaded.routes[key] = struct{}{}
+		}
+		for _, cfg := range set.files {
+			f, e := newFile(cfg.Path, cfg.Dest, cfg.RedirectTo)
+			if e != nil {
+				errs = append(errs, &LoadError{cfg.file, cfg.line, e.Error()})
+				continue
+			}
+			t.removeFile(f.path)
+			t.files = append(t.files, f)
+			loaded.files[f.path] = struct{}{}
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return loaded, nil
+}
+
+// addRoute add route of cfg to t, return key of route. Caller must hold tableMu.
+func (s *Server) addRoute(t *routeTable, cfg *routeConfig) (string, error) {
+	g := s.defGroup()
+	if cfg.Group != `` {
+		g = s.group(cfg.Group)
+	}
+	method := strings.ToUpper(cfg.Method)
+	path := g.formatPath(cfg.Path)
+	if s.normalize {
+		path = normalizePattern(path)
+	}
+	route := &Route{logger: s.logger}
+	route.UseGroup(g.name)
+	if cfg.Secure {
+		route.Secure()
+	}
+	if cfg.Summary != `` {
+		route.Summary(cfg.Summary)
+	}
+	if cfg.Database != `` {
+		route.UseDatabase(cfg.Database)
+	}
+	if ttl, e := time.ParseDuration(cfg.Cache); e == nil {
+		route.Cache(ttl)
+	}
+	for _, act := range cfg.Actions {
+		a := route.AddQueryAction(act.Query, act.Params)
+		if act.AssignTo != `` {
+			a.AssignTo(act.AssignTo)
+		}
+		if act.Database != `` {
+			a.UseDatabase(act.Database)
+		}
+		if act.Replica {
+			a.Replica()
+		}
+		switch {
+		case act.If != ``:
+			a.If(act.If)
+		case act.ElseIf != ``:
+			a.ElseIf(act.ElseIf)
+		case act.Else:
+			a.Else()
+		}
+		if act.Parallel {
+			a.Parallel()
+		}
+		for column, typ := range act.Cast {
+			a.Cast(column, typ)
+		}
+		for column, name := range act.Rename {
+			a.Rename(column, name)
+		}
+		if len(act.Omit) > 0 {
+			a.Omit(act.Omit...)
+		}
+		for _, prefix := range act.Nest {
+			a.Nest(prefix)
+		}
+		for _, prefix := range act.NestArray {
+			a.NestArray(prefix)
+		}
+		if act.GroupBy != `` {
+			a.GroupBy(act.GroupBy)
+		}
+		if at := act.AttachTo; at != nil {
+			a.AttachTo(at.Parent, at.Field, at.ParentKey, at.ChildKey)
+		}
+	}
+	existing, e := t.routers[method].add(path, route)
+	if e != nil {
+		return ``, e
+	}
+	if existing != route {
+		return ``, fmt.Errorf(`route %s %s already exists`, method, path)
+	}
+	return method + ` ` + path, nil
+}
+
+// routeFiles return .json and .sql files of paths, directory expanded to files inside it
+func routeFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		stat, e := os.Stat(path)
+		if e != nil {
+			return nil, e
+		}
+		if !stat.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, e := os.ReadDir(path)
+		if e != nil {
+			return nil, e
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case `.json`, `.sql`:
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func parseRouteFiles(paths []string) (*routeSet, error) {
+	files, e := routeFiles(paths)
+	if e != nil {
+		return nil, e
+	}
+	set := &routeSet{}
+	errs := LoadErrors{}
+	for _, file := range files {
+		data, e := os.ReadFile(file)
+		if e != nil {
+			return nil, e
+		}
+		var fileErrs LoadErrors
+		switch filepath.Ext(file) {
+		case `.sql`:
+			var cfgs []*routeConfig
+			cfgs, fileErrs = parseSQLRoutes(file, data)
+			set.routes = append(set.routes, cfgs...)
+		default:
+			var fileSet *routeSet
+			fileSet, fileErrs = parseJSONRoutes(file, data)
+			if fileSet != nil {
+				set.routes = append(set.routes, fileSet.routes...)
+				set.files = append(set.files, fileSet.files...)
+			}
+		}
+		errs = append(errs, fileErrs...)
+	}
+	for _, cfg := range set.routes {
+		errs = append(errs, cfg.validate()...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return set, nil
+}
+
+func (cfg *routeConfig) validate() LoadErrors {
+	errs := LoadErrors{}
+	fail := func(line int, format string, a ...interface{}) {
+		errs = append(errs, &LoadError{File: cfg.file, Line: line, Message: fmt.Sprintf(format, a...)})
+	}
+	method := strings.ToUpper(cfg.Method)
+	found := false
+	for _, m := range methods {
+		if m == method {
+			found = true
+		}
+	}
+	if !found {
+		fail(cfg.line, `unsupported method %q`, cfg.Method)
+	}
+	if cfg.Path == `` {
+		fail(cfg.line, `missing path`)
+	} else if _, e := (&router{}).add(cfg.Path, &Route{}); e != nil {
+		fail(cfg.line, `invalid path: %s`, e)
+	}
+	if cfg.Cache != `` {
+		if _, e := time.ParseDuration(cfg.Cache); e != nil {
+			fail(cfg.line, `invalid cache duration %q`, cfg.Cache)
+		}
+	}
+	if len(cfg.Actions) == 0 {
+		fail(cfg.line, `route has no action`)
+	}
+	for _, act := range cfg.Actions {
+		if strings.TrimSpace(act.Query) == `` {
+			fail(act.line, `missing query`)
+			continue
+		}
+		q, e := newQueryAction(act.Query, act.Params)
+		if e != nil {
+			fail(act.line, `%s`, e)
+		} else if q.qType == 0 {
+			fail(act.line, `unsupported query, must be SELECT, INSERT, UPDATE or DELETE`)
+		}
+		for column, typ := range act.Cast {
+			switch typ {
+			case castBool, castInt, castFloat, castString, castDecimal, castJSON:
+			default:
+				fail(act.line, `unsupported cast type %s for %s`, typ, column)
+			}
+		}
+		if at := act.AttachTo; at != nil && (at.Parent == `` || at.Field == `` || at.ParentKey == `` || at.ChildKey == ``) {
+			fail(act.line, `attach_to requires parent, field, parent_key and child_key`)
+		}
+		for _, cond := range []string{act.If, act.ElseIf} {
+			if cond != `` {
+				if _, e := parseCondition(cond); e != nil {
+					fail(act.line, `%s`, e)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func parseJSONRoutes(file string, data []byte) (*routeSet, LoadErrors) {
+	fail := func(offset int64, msg string) LoadErrors {
+		return LoadErrors{{File: file, Line: lineOf(data, offset), Message: msg}}
+	}
+	// failDecode report syntax error at its offset instead of start of decoded value
+	failDecode := func(offset int64, e error) LoadErrors {
+		if se, ok := e.(*json.SyntaxError); ok {
+			offset = se.Offset
+		}
+		return fail(offset, e.Error())
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, e := dec.Token(); e != nil || tok != json.Delim('{') {
+		return nil, fail(dec.InputOffset(), `expected object with routes property`)
+	}
+	set := &routeSet{}
+	for dec.More() {
+		key, e := dec.Token()
+		if e != nil {
+			return nil, fail(dec.InputOffset(), e.Error())
+		}
+		if key != `routes` && key != `files` {
+			var skip json.RawMessage
+			if e := dec.Decode(&skip); e != nil {
+				return nil, failDecode(dec.InputOffset(), e)
+			}
+			continue
+		}
+		if tok, e := dec.Token(); e != nil || tok != json.Delim('[') {
+			return nil, fail(dec.InputOffset(), fmt.Sprintf(`%s must be an array`, key))
+		}
+		for dec.More() {
+			start := dec.InputOffset()
+			var raw json.RawMessage
+			if e := dec.Decode(&raw); e != nil {
+				return nil, failDecode(dec.InputOffset(), e)
+			}
+			line := lineOf(data, start+int64(len(leadingSpace(data[start:]))))
+			if key == `files` {
+				cfg := &fileConfig{file: file, line: line}
+				if e := json.Unmarshal(raw, cfg); e != nil {
+					return nil, fail(start, e.Error())
+				}
+				set.files = append(set.files, cfg)
+				continue
+			}
+			cfg := &routeConfig{file: file, line: line}
+			if e := json.Unmarshal(raw, cfg); e != nil {
+				return nil, fail(start, e.Error())
+			}
+			// action lines located by searching each query inside route definition
+			offset := 0
+			for i := range cfg.Actions {
+				cfg.Actions[i].line = line
+				if q, e := json.Marshal(cfg.Actions[i].Query); e == nil {
+					if idx := bytes.Index(raw[offset:], q[1:len(q)-1]); idx >= 0 {
+						offset += idx
+						cfg.Actions[i].line = line + bytes.Count(raw[:offset], []byte("\n"))
+					}
+				}
+			}
+			set.routes = append(set.routes, cfg)
+		}
+		if _, e := dec.Token(); e != nil {
+			return nil, fail(dec.InputOffset(), e.Error())
+		}
+	}
+	return set, nil
+}
+
+func parseSQLRoutes(file string, data []byte) ([]*routeConfig, LoadErrors) {
+	cfgs := []*routeConfig{}
+	errs := LoadErrors{}
+	var cfg *routeConfig
+	act := actionConfig{}
+	query := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, `-- @`) {
+			if len(query) > 0 {
+				errs = append(errs, &LoadError{file, act.line, `statement must end with semicolon`})
+				query = query[:0]
+			}
+			fields := strings.Fields(line[4:])
+			if len(fields) == 0 {
+				errs = append(errs, &LoadError{file, lineNum, `missing annotation name`})
+				continue
+			}
+			name, value := fields[0], strings.TrimSpace(strings.TrimPrefix(line[4:], fields[0]))
+			switch name {
+			case `route`:
+				parts := strings.Fields(value)
+				if len(parts) != 2 {
+					errs = append(errs, &LoadError{file, lineNum, `route annotation must be: @route METHOD PATH`})
+					cfg = nil
+					continue
+				}
+				cfg = &routeConfig{Method: parts[0], Path: parts[1], file: file, line: lineNum}
+				cfgs = append(cfgs, cfg)
+				act = actionConfig{}
+				continue
+			}
+			if cfg == nil {
+				errs = append(errs, &LoadError{file, lineNum, fmt.Sprintf(`@%s without @route`, name)})
+				continue
+			}
+			switch name {
+			case `group`:
+				cfg.Group = value
+			case `secure`:
+				cfg.Secure = true
+			case `summary`:
+				cfg.Summary = value
+			case `cache`:
+				cfg.Cache = value
+			case `database`:
+				act.Database = value
+			case `params`:
+				act.Params = value
+			case `assign`:
+				act.AssignTo = value
+			case `if`:
+				act.If = value
+			case `elseif`:
+				act.ElseIf = value
+			case `else`:
+				act.Else = true
+			case `parallel`:
+				act.Parallel = true
+			case `replica`:
+				act.Replica = true
+			case `cast`, `rename`:
+				parts := strings.Fields(value)
+				if len(parts) != 2 {
+					errs = append(errs, &LoadError{file, lineNum, fmt.Sprintf(`@%s must be: @%s COLUMN VALUE`, name, name)})
+					continue
+				}
+				if name == `cast` {
+					if act.Cast == nil {
+						act.Cast = make(map[string]string)
+					}
+					act.Cast[parts[0]] = parts[1]
+				} else {
+					if act.Rename == nil {
+						act.Rename = make(map[string]string)
+					}
+					act.Rename[parts[0]] = parts[1]
+				}
+			case `omit`:
+				for _, column := range strings.Split(value, `,`) {
+					act.Omit = append(act.Omit, strings.TrimSpace(column))
+				}
+			case `nest`:
+				act.Nest = append(act.Nest, value)
+			case `nestarray`:
+				act.NestArray = append(act.NestArray, value)
+			case `groupby`:
+				act.GroupBy = value
+			case `attach`:
+				parts := strings.Fields(value)
+				if len(parts) != 4 {
+					errs = append(errs, &LoadError{file, lineNum, `@attach must be: @attach PARENT FIELD PARENT_KEY CHILD_KEY`})
+					continue
+				}
+				act.AttachTo = &attachConfig{parts[0], parts[1], parts[2], parts[3]}
+			default:
+				errs = append(errs, &LoadError{file, lineNum, fmt.Sprintf(`unknown annotation @%s`, name)})
+			}
+			continue
+		}
+		if line == `` || strings.HasPrefix(line, `--`) {
+			continue
+		}
+		if cfg == nil {
+			errs = append(errs, &LoadError{file, lineNum, `statement without @route`})
+			continue
+		}
+		if len(query) == 0 {
+			act.line = lineNum
+		}
+		query = append(query, line)
+		if strings.HasSuffix(line, `;`) {
+			act.Query = strings.TrimSuffix(strings.Join(query, ` `), `;`)
+			cfg.Actions = append(cfg.Actions, act)
+			act = actionConfig{}
+			query = query[:0]
+		}
+	}
+	if e := scanner.Err(); e != nil {
+		errs = append(errs, &LoadError{file, lineNum, e.Error()})
+	}
+	if len(query) > 0 {
+		errs = append(errs, &LoadError{file, act.line, `statement must end with semicolon`})
+	}
+	return cfgs, errs
+}
+
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func leadingSpace(data []byte) []byte {
+	for i, b := range data {
+		switch b {
+		case ' ', '\t', '\r', '\n', ',':
+		default:
+			return data[:i]
+		}
+	}
+	return data
+}
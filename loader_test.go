@@ -0,0 +1,123 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeRouteFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if e := os.WriteFile(file, []byte(content), 0o644); e != nil {
+		t.Fatal(e)
+	}
+	return file
+}
+
+// loadErrors return errors of LoadRoutes as file:line: message strings
+func loadErrors(t *testing.T, s *Server, paths ...string) []string {
+	t.Helper()
+	e := s.LoadRoutes(paths...)
+	errs, ok := e.(LoadErrors)
+	if !ok {
+		t.Fatalf(`expected LoadErrors, got %v`, e)
+	}
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return msgs
+}
+
+func TestLoadJSONRoutesErrorLine(t *testing.T) {
+	dir := t.TempDir()
+	file := writeRouteFile(t, dir, `routes.json`, `{"routes": [
+  {"method": "GET", "path": "/users",
+   "actions": [{"query": "SELECT * FROM users"}]},
+  {"method": "FETCH", "path": "/items", "cache": "soon",
+   "actions": [
+     {"query": "SELECT * FROM items"},
+     {"query": "TRUNCATE items"},
+     {"query": "SELECT * FROM items", "if": "$session.role ~ admin", "cast": {"price": "money"}}
+   ]}
+]}`)
+	s := newTestServer()
+	expected := []string{
+		file + `:4: unsupported method "FETCH"`,
+		file + `:4: invalid cache duration "soon"`,
+		file + `:7: unsupported query, must be SELECT, INSERT, UPDATE or DELETE`,
+		file + `:8: unsupported cast type money for price`,
+		file + `:8: invalid condition: $session.role ~ admin`,
+	}
+	if errs := loadErrors(t, s, dir); !reflect.DeepEqual(errs, expected) {
+		t.Errorf("expected errors:\n%v\ngot:\n%v", expected, errs)
+	}
+	if route, _ := s.routes().findRoute(MethodGet, `/users`); route != nil {
+		t.Error(`route registered from file with errors`)
+	}
+}
+
+func TestLoadJSONSyntaxErrorLine(t *testing.T) {
+	file := writeRouteFile(t, t.TempDir(), `routes.json`, "{\"routes\": [\n  {\"method\": \"GET\",\n   \"path\": }\n]}")
+	errs := loadErrors(t, newTestServer(), file)
+	if len(errs) != 1 || errs[0][:len(file)+3] != file+`:3:` {
+		t.Errorf(`expected syntax error on line 3, got %v`, errs)
+	}
+}
+
+func TestLoadSQLRoutesErrorLine(t *testing.T) {
+	file := writeRouteFile(t, t.TempDir(), `routes.sql`, `SELECT 1;
+
+-- @route GET /users
+-- @unknown value
+SELECT * FROM users;
+
+-- @route GET
+-- @route POST /users
+-- @params name
+INSERT INTO users (name) VALUES (?)
+`)
+	expected := []string{
+		file + `:1: statement without @route`,
+		file + `:4: unknown annotation @unknown`,
+		file + `:7: route annotation must be: @route METHOD PATH`,
+		file + `:10: statement must end with semicolon`,
+		file + `:8: route has no action`,
+	}
+	if errs := loadErrors(t, newTestServer(), file); !reflect.DeepEqual(errs, expected) {
+		t.Errorf("expected errors:\n%v\ngot:\n%v", expected, errs)
+	}
+}
+
+func TestLoadRoutesDuplicateLine(t *testing.T) {
+	dir := t.TempDir()
+	writeRouteFile(t, dir, `a.sql`, "-- @route GET /users\nSELECT * FROM users;\n")
+	file := writeRouteFile(t, dir, `b.json`, `{"routes": [
+  {"method": "GET", "path": "/users", "actions": [{"query": "SELECT * FROM users"}]}
+]}`)
+	expected := []string{file + `:2: route GET /users already exists`}
+	if errs := loadErrors(t, newTestServer(), dir); !reflect.DeepEqual(errs, expected) {
+		t.Errorf(`expected %v, got %v`, expected, errs)
+	}
+}
+
+func TestLoadRoutes(t *testing.T) {
+	file := writeRouteFile(t, t.TempDir(), `routes.sql`, `-- @route GET /users/:id
+-- @params :id:int
+-- @assign user
+SELECT * FROM users WHERE id = ? LIMIT 1;
+`)
+	s := newTestServer()
+	if e := s.LoadRoutes(file); e != nil {
+		t.Fatal(e)
+	}
+	route, params := s.routes().findRoute(MethodGet, `/users/7`)
+	if route == nil || params[`id`] != `7` {
+		t.Fatal(`route not registered`)
+	}
+	if len(route.action) != 1 || route.action[0].property() != `user` {
+		t.Errorf(`unexpected actions %v`, route.action)
+	}
+}
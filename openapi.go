@@ -0,0 +1,211 @@
+package api
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/eqto/go-json"
+)
+
+var (
+	openAPIArrayRegex = regexp.MustCompile(`^([a-zA-Z0-9._]+)\[([a-zA-Z0-9._]*)\]$`)
+)
+
+type routeDoc struct {
+	summary     string
+	description string
+	tags        []string
+	request     json.Object
+	response    json.Object
+	hidden      bool
+}
+
+// OpenAPI generate OpenAPI 3.1 document from all registered routes
+func (s *Server) OpenAPI(title, version string) json.Object {
+	paths := json.Object{}
+	secure := false
+	t := s.routes()
+	for _, method := range methods {
+		t.routers[method].walk(func(path string, route *Route) {
+			if route.ws != nil || route.doc.hidden {
+				return
+			}
+			docPath, params := openAPIPath(path)
+			item, ok := paths[docPath].(json.Object)
+			if !ok {
+				item = json.Object{}
+				paths.Put(docPath, item)
+			}
+			item.Put(strings.ToLower(method), route.openAPIOperation(method, params))
+			if route.secure {
+				secure = true
+			}
+		})
+	}
+	doc := json.Object{
+		`openapi`: `3.1.0`,
+		`info`:    json.Object{`title`: title, `version`: version},
+		`paths`:   paths,
+	}
+	if secure {
+		doc.Put(`components`, json.Object{
+			`securitySchemes`: json.Object{
+				`bearerAuth`: json.Object{`type`: `http`, `scheme`: `bearer`, `bearerFormat`: `JWT`},
+			},
+		})
+	}
+	return doc
+}
+
+// ServeOpenAPI serve OpenAPI document on path, document generated on each request so routes added later are included
+func (s *Server) ServeOpenAPI(path, title, version string) *Route {
+	route := s.Get(path)
+	route.doc.hidden = true
+	route.ResetActions().AddAction(func(ctx *Context) error {
+		return ctx.WriteBody(`application/json`, s.OpenAPI(title, version).Bytes())
+	})
+	return route
+}
+
+func (r *Route) openAPIOperation(method string, pathParams []json.Object) json.Object {
+	op := json.Object{}
+	if r.doc.summary != `` {
+		op.Put(`summary`, r.doc.summary)
+	}
+	if r.doc.description != `` {
+		op.Put(`description`, r.doc.description)
+	}
+	if len(r.doc.tags) > 0 {
+		op.Put(`tags`, r.doc.tags)
+	} else if r.group != `` {
+		op.Put(`tags`, []string{r.group})
+	}
+	if r.secure {
+		op.Put(`security`, []json.Object{{`bearerAuth`: []string{}}})
+	}
+
+	params := pathParams
+	bodyProps := json.Object{}
+	required := []string{}
+	for _, act := range r.action {
+		types := map[string]string{}
+		if q, ok := act.(*actionQuery); ok {
+			types = q.qTypes
+		}
+		for _, param := range act.params() {
+			if strings.HasPrefix(param, `$`) || strings.HasPrefix(param, `:`) {
+				continue
+			}
+			schema := json.Object{}
+			switch types[param] {
+			case `int`:
+				schema.Put(`type`, `integer`)
+			case `float`:
+				schema.Put(`type`, `number`)
+			case `bool`:
+				schema.Put(`type`, `boolean`)
+			case `string`:
+				schema.Put(`type`, `string`)
+			}
+			if matches := openAPIArrayRegex.FindStringSubmatch(param); len(matches) == 3 {
+				items := json.Object{`type`: `object`}
+				if arr, ok := bodyProps[matches[1]].(json.Object); ok {
+					if arrItems, ok := arr[`items`].(json.Object); ok {
+						items = arrItems
+					}
+				}
+				if matches[2] != `` {
+					props, ok := items[`properties`].(json.Object)
+					if !ok {
+						props = json.Object{}
+						items.Put(`properties`, props)
+					}
+					props.Put(matches[2], schema)
+				}
+				bodyProps.Put(matches[1], json.Object{`type`: `array`, `items`: items})
+				continue
+			}
+			if method == MethodGet || method == MethodHead || method == MethodDelete {
+				params = append(params, json.Object{`name`: param, `in`: `query`, `required`: true, `schema`: schema})
+			} else if !bodyProps.Has(param) {
+				bodyProps.Put(param, schema)
+				required = append(required, param)
+			}
+		}
+	}
+	reqSchema := r.doc.request
+	if reqSchema == nil && len(r.fields) > 0 {
+		bodyFields := []*Field{}
+		for _, field := range r.fields {
+			switch {
+			case field.source == fieldSourcePath:
+			case field.source == fieldSourceQuery, method == MethodGet || method == MethodHead || method == MethodDelete:
+				params = append(params, json.Object{`name`: field.name, `in`: `query`, `required`: field.required, `schema`: field.schema()})
+			default:
+				bodyFields = append(bodyFields, field)
+			}
+		}
+		if len(bodyFields) > 0 {
+			reqSchema = fieldsSchema(bodyFields)
+		}
+	}
+	if reqSchema == nil && len(bodyProps) > 0 {
+		reqSchema = json.Object{`type`: `object`, `properties`: bodyProps}
+		if len(required) > 0 {
+			reqSchema.Put(`required`, required)
+		}
+	}
+	if len(params) > 0 {
+		op.Put(`parameters`, params)
+	}
+	if reqSchema != nil {
+		op.Put(`requestBody`, json.Object{
+			`content`: json.Object{`application/json`: json.Object{`schema`: reqSchema}},
+		})
+	}
+
+	respProps := json.Object{
+		`status`:  json.Object{`type`: `integer`},
+		`message`: json.Object{`type`: `string`},
+	}
+	if r.doc.response != nil {
+		respProps.Put(`data`, r.doc.response)
+	} else {
+		respProps.Put(`data`, json.Object{})
+	}
+	op.Put(`responses`, json.Object{
+		`200`: json.Object{
+			`description`: `Success`,
+			`content`: json.Object{`application/json`: json.Object{
+				`schema`: json.Object{`type`: `object`, `properties`: respProps},
+			}},
+		},
+	})
+	return op
+}
+
+// openAPIPath convert route pattern to OpenAPI path template, ex: /users/:id(\d+) to /users/{id}
+func openAPIPath(path string) (string, []json.Object) {
+	params := []json.Object{}
+	segments := splitPath(path)
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, `:`):
+			name, regex, _ := parseParamSegment(seg)
+			schema := json.Object{`type`: `string`}
+			if regex != nil {
+				schema.Put(`pattern`, regex.String())
+			}
+			params = append(params, json.Object{`name`: name, `in`: `path`, `required`: true, `schema`: schema})
+			segments[i] = `{` + name + `}`
+		case strings.HasPrefix(seg, `*`):
+			name := seg[1:]
+			if name == `` {
+				name = `*`
+			}
+			params = append(params, json.Object{`name`: name, `in`: `path`, `required`: true, `schema`: json.Object{`type`: `string`}})
+			segments[i] = `{` + name + `}`
+		}
+	}
+	return `/` + strings.Join(segments, `/`), params
+}
@@ -9,3 +9,12 @@ func OptionTimeout(timeout time.Duration) ServerOptions {
 		}
 	}
 }
+
+// OptionDrainDelay set delay between readiness flipped to not ready and stop accepting connections on shutdown, giving load balancer time to stop routing requests
+func OptionDrainDelay(delay time.Duration) ServerOptions {
+	return func(s *Server) {
+		if s != nil {
+			s.drainDelay = delay
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eqto/go-json"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	branchNone = iota
+	branchIf
+	branchElseIf
+	branchElse
+)
+
+var conditionOperators = []string{`==`, `!=`, `>=`, `<=`, `>`, `<`}
+
+// actionFlow pipeline options of action
+type actionFlow struct {
+	cond     *condition
+	branch   uint8
+	parallel bool
+	// err invalid option set on action, returned by Serve and when route executed
+	err error
+}
+
+func (f *actionFlow) setBranch(branch uint8, cond string) {
+	f.branch = branch
+	f.cond = nil
+	if cond != `` {
+		c, e := parseCondition(cond)
+		if e != nil {
+			f.err = e
+			return
+		}
+		f.cond = c
+	}
+}
+
+// checkActions return first invalid action option of routes
+func checkActions(t *routeTable) error {
+	for _, method := range methods {
+		var err error
+		t.routers[method].walk(func(path string, route *Route) {
+			for _, act := range route.action {
+				if e := act.flow().err; e != nil && err == nil {
+					err = fmt.Errorf(`route %s %s: %s`, method, path, e)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// condition compare operand with literal value, without operator operand is checked for truthiness. Operand is $var (nested field using dot, ex: $user.role), $session.name, :param or request field.
+type condition struct {
+	operand string
+	op      string
+	value   interface{}
+}
+
+// parseCondition parse condition, ex: `$user != null`, `status == active`, `:id > 0`, `!$orders`
+func parseCondition(str string) (*condition, error) {
+	str = strings.TrimSpace(str)
+	if str == `` {
+		return nil, fmt.Errorf(`empty condition`)
+	}
+	for _, op := range conditionOperators {
+		if idx := strings.Index(str, op); idx > 0 {
+			operand := strings.TrimSpace(str[:idx])
+			if operand == `` || strings.ContainsAny(operand, ` =!<>`) {
+				return nil, fmt.Errorf(`invalid condition: %s`, str)
+			}
+			return &condition{operand: operand, op: op, value: parseLiteral(strings.TrimSpace(str[idx+len(op):]))}, nil
+		}
+	}
+	if strings.HasPrefix(str, `!`) {
+		return &condition{operand: strings.TrimSpace(str[1:]), op: `!`}, nil
+	}
+	if strings.ContainsAny(str, ` =<>`) {
+		return nil, fmt.Errorf(`invalid condition: %s`, str)
+	}
+	return &condition{operand: str}, nil
+}
+
+func parseLiteral(str string) interface{} {
+	switch str {
+	case `null`:
+		return nil
+	case `true`:
+		return true
+	case `false`:
+		return false
+	}
+	if len(str) >= 2 && (str[0] == '\'' || str[0] == '"') && str[len(str)-1] == str[0] {
+		return str[1 : len(str)-1]
+	}
+	if f, e := strconv.ParseFloat(str, 64); e == nil {
+		return f
+	}
+	return str
+}
+
+func (c *condition) eval(ctx *Context) bool {
+	val := ctx.lookup(c.operand)
+	switch c.op {
+	case ``:
+		return truthy(val)
+	case `!`:
+		return !truthy(val)
+	case `==`:
+		return equalValue(val, c.value)
+	case `!=`:
+		return !equalValue(val, c.value)
+	}
+	a, ok := toFloat(val)
+	if !ok {
+		return false
+	}
+	b, ok := toFloat(c.value)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case `>`:
+		return a > b
+	case `>=`:
+		return a >= b
+	case `<`:
+		return a < b
+	case `<=`:
+		return a <= b
+	}
+	return false
+}
+
+// lookup return value of $var, $session.name, :param or request field
+func (c *Context) lookup(name string) interface{} {
+	switch {
+	case strings.HasPrefix(name, `$session.`):
+		return c.Session().Get(name[9:])
+	case strings.HasPrefix(name, `$`):
+		path := strings.Split(name[1:], `.`)
+		var val interface{} = map[string]interface{}(c.vars)
+		for _, key := range path {
+			val = fieldOf(val, key)
+		}
+		return val
+	case strings.HasPrefix(name, `:`):
+		if val, ok := c.params[name[1:]]; ok {
+			return val
+		}
+		return nil
+	}
+	return c.req.get(name)
+}
+
+// fieldOf return field of object or resultset, pointer values dereferenced
+func fieldOf(obj interface{}, key string) interface{} {
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Map || val.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	field := val.MapIndex(reflect.ValueOf(key).Convert(val.Type().Key()))
+	if !field.IsValid() {
+		return nil
+	}
+	return derefValue(field.Interface())
+}
+
+func derefValue(value interface{}) interface{} {
+	val := reflect.ValueOf(value)
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return nil
+	}
+	return val.Interface()
+}
+
+func truthy(value interface{}) bool {
+	value = derefValue(value)
+	switch val := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ``
+	}
+	if f, ok := toFloat(value); ok {
+		return f != 0
+	}
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return val.Len() > 0
+	}
+	return true
+}
+
+func equalValue(a, b interface{}) bool {
+	a = derefValue(a)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if fb, ok := b.(float64); ok {
+		if fa, ok := toFloat(a); ok {
+			return fa == fb
+		}
+	}
+	if bb, ok := b.(bool); ok {
+		if ba, ok := toBool(a); ok {
+			return ba == bb
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// executeParallel execute actions concurrently, each with its own copy of context outside request transaction. Results and vars merged into ctx in action order after all finished.
+func executeParallel(ctx *Context, actions []Action) error {
+	// load lazily initialized values before sharing them between goroutines
+	ctx.req.JSON()
+	ctx.req.URL()
+	ctx.Session()
+
+	children := make([]*Context, len(actions))
+	errs := make([]error, len(actions))
+	wg := sync.WaitGroup{}
+	for i, action := range actions {
+		children[i] = ctx.fork()
+		wg.Add(1)
+		go func(i int, action Action) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf(`%v`, r)
+				}
+			}()
+			children[i].property = action.property()
+			errs[i] = action.execute(children[i])
+		}(i, action)
+	}
+	wg.Wait()
+	for i, child := range children {
+		ctx.debugLog = append(ctx.debugLog, child.debugLog...)
+		ctx.mergeHeader(child)
+		ctx.invalidateTags = append(ctx.invalidateTags, child.invalidateTags...)
+		if errs[i] != nil {
+			if child.retryable {
+				ctx.retryable = true
+			}
+			if code := child.resp.httpResp.StatusCode(); code != StatusOK {
+				ctx.resp.httpResp.SetStatusCode(code)
+			}
+			ctx.resp.statusCode = child.resp.statusCode
+			ctx.resp.statusMsg = child.resp.statusMsg
+			if child.resp.err != nil {
+				ctx.setErr(child.resp.err)
+			}
+			return errs[i]
+		}
+	}
+	for _, child := range children {
+		for key, val := range child.resp.data {
+			ctx.resp.put(key, val)
+		}
+		for key, val := range child.vars {
+			if ctx.vars == nil {
+				ctx.vars = json.Object{}
+			}
+			ctx.vars.Put(key, val)
+		}
+		for property, page := range child.resp.pages {
+			ctx.resp.setPage(property, page)
+		}
+		if child.resp.stop {
+			ctx.resp.stop = true
+		}
+	}
+	return nil
+}
+
+// fork return copy of context for parallel action with its own request, response and session, queries executed without transaction
+func (c *Context) fork() *Context {
+	var vars json.Object
+	if c.vars != nil {
+		vars = c.vars.Clone()
+	}
+	values := make(map[string]interface{}, len(c.values))
+	for key, val := range c.values {
+		values[key] = val
+	}
+	fastCtx := &fasthttp.RequestCtx{}
+	fastCtx.Init(&c.fastCtx.Request, c.fastCtx.RemoteAddr(), nil)
+	fastCtx.Response.Header.SetNoDefaultContentType(true)
+	req := *c.req
+	req.fastCtx = fastCtx
+	return &Context{
+		s:        c.s,
+		fastCtx:  fastCtx,
+		req:      &req,
+		resp:     &Response{httpResp: &fastCtx.Response},
+		sess:     c.sess.clone(),
+		vars:     vars,
+		values:   values,
+		params:   c.params,
+		dbName:   c.dbName,
+		tx:       c.tx,
+		parallel: true,
+	}
+}
+
+// mergeHeader copy headers set by parallel action into response
+func (c *Context) mergeHeader(child *Context) {
+	header := &c.resp.httpResp.Header
+	child.resp.httpResp.Header.VisitAll(func(key, value []byte) {
+		switch string(key) {
+		case `Content-Length`:
+		case `Set-Cookie`:
+			header.AddBytesKV(key, value)
+		default:
+			header.SetBytesKV(key, value)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBranchCondition(t *testing.T) {
+	s := newTestServer()
+	write := func(value string) func(*Context) error {
+		return func(ctx *Context) error { return ctx.Write(value) }
+	}
+	route := s.Get(`/greet`)
+	route.AddAction(write(`admin`)).If(`role == admin`)
+	route.AddAction(write(`user`)).ElseIf(`role == 'user'`)
+	route.AddAction(write(`guest`)).Else()
+	route.AddAction(write(`vip`)).If(`level >= 10`)
+	client := serveTest(t, s)
+
+	tests := map[string]string{
+		`/greet?role=admin`:          `admin`,
+		`/greet?role=user`:           `user`,
+		`/greet?role=other`:          `guest`,
+		`/greet`:                     `guest`,
+		`/greet?role=admin&level=12`: `vip`,
+		`/greet?role=admin&level=9`:  `admin`,
+	}
+	for uri, expected := range tests {
+		resp := doTest(t, client, MethodGet, uri, nil)
+		body := struct {
+			Data string `json:"data"`
+		}{}
+		if e := json.Unmarshal(resp.Body(), &body); e != nil {
+			t.Fatalf(`%s: %s: %s`, uri, e, resp.Body())
+		}
+		if body.Data != expected {
+			t.Errorf(`%s: expected %s, got %s`, uri, expected, body.Data)
+		}
+	}
+}
+
+func TestBranchInvalidCondition(t *testing.T) {
+	s := newTestServer()
+	route := s.Get(`/greet`)
+	route.AddAction(func(*Context) error { return nil }).If(`role ~ admin`)
+	ctx := newTestContext(t, s, MethodGet, `/greet`, nil)
+	if e := route.executeActions(ctx); e == nil || !strings.Contains(e.Error(), `invalid condition`) {
+		t.Errorf(`expected invalid condition error, got %v`, e)
+	}
+	if e := s.Serve(0); e == nil || !strings.Contains(e.Error(), `route GET /greet: invalid condition`) {
+		t.Errorf(`expected Serve to fail with invalid condition, got %v`, e)
+	}
+}
+
+func TestLoopOverPreviousResult(t *testing.T) {
+	s := newTestServer()
+	cn, db := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	db.handler = func(query string, args []interface{}) (*testResult, error) {
+		if strings.HasPrefix(query, `SELECT id FROM orders`) {
+			return &testResult{cols: []string{`id`}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}}, nil
+		}
+		if strings.HasPrefix(query, `SELECT name FROM items`) {
+			return &testResult{cols: []string{`name`}, rows: [][]driver.Value{{fmt.Sprintf(`item-%v`, args[0])}}}, nil
+		}
+		return nil, nil
+	}
+	route := s.Get(`/items`)
+	route.AddQueryAction(`SELECT id FROM orders`, ``).AssignTo(`$orders`)
+	route.AddQueryAction(`SELECT name FROM items WHERE order_id = ?`, `$orders[id]`).If(`$orders`)
+
+	resp := doTest(t, serveTest(t, s), MethodGet, `/items`, nil)
+	body := struct {
+		Data []map[string]string `json:"data"`
+	}{}
+	if e := json.Unmarshal(resp.Body(), &body); e != nil {
+		t.Fatalf(`%s: %s`, e, resp.Body())
+	}
+	expected := []map[string]string{{`name`: `item-1`}, {`name`: `item-2`}}
+	if !reflect.DeepEqual(body.Data, expected) {
+		t.Errorf(`expected %v, got %v`, expected, body.Data)
+	}
+	if query, args := db.last(`SELECT name`); query != `SELECT name FROM items WHERE order_id = ? LIMIT 0, 1000` || fmt.Sprint(args) != `[2]` {
+		t.Errorf(`unexpected query %s %v`, query, args)
+	}
+}
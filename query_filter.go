@@ -0,0 +1,224 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eqto/go-json"
+)
+
+// Filter types, client send it as filter type (case insensitive):
+//
+//	{
+//	  "filters": [
+//	    {"name": "title", "value": "go", "type": "contains"},
+//	    {"name": "price", "value": [10, 20], "type": "between"},
+//	    {"or": [
+//	      {"name": "author", "value": "john", "type": "ieq"},
+//	      {"name": "deleted_at", "type": "null"}
+//	    ]}
+//	  ]
+//	}
+//
+// Object shape is also supported, with group as key: {"title": {"value": "go"}, "or": [...]}
+var filterOperators = map[string]struct{}{
+	`=`: {}, `!=`: {}, `<>`: {}, `<`: {}, `<=`: {}, `>`: {}, `>=`: {},
+	`IEQ`: {}, `LIKE`: {}, `CONTAINS`: {}, `STARTSWITH`: {}, `ENDSWITH`: {},
+	`IN`: {}, `NOT IN`: {}, `BETWEEN`: {}, `NULL`: {}, `NOT NULL`: {},
+	`FULLTEXT`: {}, `DATE`: {}, `DATERANGE`: {},
+}
+
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`, `[`, `![`)
+
+func filterGroupOf(key string) string {
+	switch strings.ToLower(key) {
+	case `or`:
+		return ` OR `
+	case `and`:
+		return ` AND `
+	}
+	return ``
+}
+
+// buildFilterItem build filter of array shape, item is either single filter with name or a group
+func (q *actionQuery) buildFilterItem(item json.Object) (string, []interface{}, error) {
+	for key := range item {
+		if group := filterGroupOf(key); group != `` {
+			return q.buildFilterGroup(item.GetArray(key), group)
+		}
+	}
+	return q.buildFilter(item.GetString(`name`), item)
+}
+
+func (q *actionQuery) buildFilterGroup(items []json.Object, group string) (string, []interface{}, error) {
+	if len(items) == 0 {
+		return ``, nil, errors.New(`empty filter group`)
+	}
+	conds := []string{}
+	values := []interface{}{}
+	for _, item := range items {
+		cond, vals, e := q.buildFilterItem(item)
+		if e != nil {
+			return ``, nil, e
+		}
+		conds = append(conds, cond)
+		values = append(values, vals...)
+	}
+	return `(` + strings.Join(conds, group) + `)`, values, nil
+}
+
+// buildFilter validate filter against declared filters and return its where condition, filter not declared using Filter is rejected
+func (q *actionQuery) buildFilter(name string, filter json.Object) (string, []interface{}, error) {
+	if filter == nil {
+		return ``, nil, fmt.Errorf(`invalid filter: %s`, name)
+	}
+	typ := filter.GetString(`type`)
+	op := normalizeOperator(typ)
+	if _, ok := filterOperators[op]; !ok {
+		return ``, nil, fmt.Errorf(`unsupported filter type: %s`, typ)
+	}
+	f, ok := q.filters[name]
+	if !ok {
+		return ``, nil, fmt.Errorf(`filter not allowed: %s`, name)
+	}
+	if f.operators != nil {
+		if _, ok := f.operators[op]; !ok {
+			return ``, nil, fmt.Errorf(`filter type %s not allowed for %s`, typ, name)
+		}
+	}
+	cond, values, e := parseFilter(f.column, op, filter)
+	if e != nil {
+		return ``, nil, fmt.Errorf(`invalid filter %s: %s`, name, e)
+	}
+	return cond, values, nil
+}
+
+func normalizeOperator(typ string) string {
+	op := strings.Join(strings.Fields(strings.ToUpper(typ)), ` `)
+	switch op {
+	case ``:
+		return `=`
+	case `IS NULL`:
+		return `NULL`
+	case `IS NOT NULL`:
+		return `NOT NULL`
+	}
+	return op
+}
+
+// parseFilter return where condition and its bound values
+func parseFilter(column, op string, filter json.Object) (string, []interface{}, error) {
+	value := filter.Get(`value`)
+	switch op {
+	case `FULLTEXT`:
+		return fmt.Sprintf(`MATCH(%s) AGAINST(? IN BOOLEAN MODE)`, column), []interface{}{filterString(value) + `*`}, nil
+	case `DATE`, `DATERANGE`:
+		from, to := filterString(value), ``
+		if op == `DATERANGE` {
+			list := filterList(value)
+			if len(list) != 2 {
+				return ``, nil, errors.New(`date range requires 2 values`)
+			}
+			from, to = filterString(list[0]), filterString(list[1])
+		}
+		start, end, e := dateRange(from, to, filter.GetString(`timezone`))
+		if e != nil {
+			return ``, nil, e
+		}
+		return fmt.Sprintf(`(%s >= ? AND %s < ?)`, column, column), []interface{}{start, end}, nil
+	case `IN`, `NOT IN`:
+		list := filterList(value)
+		if len(list) == 0 {
+			return ``, nil, errors.New(`empty value`)
+		}
+		holders := make([]string, len(list))
+		for i := range list {
+			holders[i] = `?`
+		}
+		return fmt.Sprintf(`%s %s (%s)`, column, op, strings.Join(holders, `,`)), list, nil
+	case `BETWEEN`:
+		list := filterList(value)
+		if len(list) != 2 {
+			return ``, nil, errors.New(`between requires 2 values`)
+		}
+		return fmt.Sprintf(`%s BETWEEN ? AND ?`, column), list, nil
+	case `CONTAINS`, `STARTSWITH`, `ENDSWITH`:
+		val := likeEscaper.Replace(filterString(value))
+		switch op {
+		case `CONTAINS`:
+			val = `%` + val + `%`
+		case `STARTSWITH`:
+			val = val + `%`
+		case `ENDSWITH`:
+			val = `%` + val
+		}
+		return fmt.Sprintf(`%s LIKE ? ESCAPE '!'`, column), []interface{}{val}, nil
+	case `IEQ`:
+		return fmt.Sprintf(`LOWER(%s) = LOWER(?)`, column), []interface{}{filterString(value)}, nil
+	case `NULL`:
+		return fmt.Sprintf(`%s IS NULL`, column), nil, nil
+	case `NOT NULL`:
+		return fmt.Sprintf(`%s IS NOT NULL`, column), nil, nil
+	}
+	if value == nil {
+		return ``, nil, errors.New(`missing value`)
+	}
+	return fmt.Sprintf(`%s %s ?`, column, op), []interface{}{value}, nil
+}
+
+// dateRange return start of from date and start of the day after to date. With timezone, dates are interpreted in timezone and returned as UTC datetime, to compare with timestamps stored in UTC.
+func dateRange(from, to, timezone string) (string, string, error) {
+	if to == `` {
+		to = from
+	}
+	loc := time.UTC
+	if timezone != `` {
+		l, e := time.LoadLocation(timezone)
+		if e != nil {
+			return ``, ``, fmt.Errorf(`invalid timezone %s`, timezone)
+		}
+		loc = l
+	}
+	start, e := time.ParseInLocation(`2006-01-02`, from, loc)
+	if e != nil {
+		return ``, ``, fmt.Errorf(`invalid date %s`, from)
+	}
+	end, e := time.ParseInLocation(`2006-01-02`, to, loc)
+	if e != nil {
+		return ``, ``, fmt.Errorf(`invalid date %s`, to)
+	}
+	end = end.AddDate(0, 0, 1)
+	if timezone == `` {
+		return start.Format(`2006-01-02`), end.Format(`2006-01-02`), nil
+	}
+	return start.UTC().Format(`2006-01-02 15:04:05`), end.UTC().Format(`2006-01-02 15:04:05`), nil
+}
+
+func filterString(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ``
+	case string:
+		return value
+	}
+	return fmt.Sprint(value)
+}
+
+// filterList return values of array, or comma separated string
+func filterList(value interface{}) []interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		return value
+	case string:
+		list := []interface{}{}
+		for _, val := range strings.Split(value, `,`) {
+			list = append(list, strings.TrimSpace(val))
+		}
+		return list
+	case nil:
+		return nil
+	}
+	return []interface{}{value}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eqto/go-json"
+)
+
+func testFilterQuery() *actionQuery {
+	q, _ := newQueryAction(`SELECT * FROM books`, ``)
+	q.Filter(`title`, `b.title`, `contains`, `startswith`, `endswith`)
+	q.Filter(`author`, `b.author`)
+	q.Filter(`price`, `b.price`, `between`, `in`, `not in`)
+	q.Filter(`deleted`, `b.deleted_at`, `null`, `not null`)
+	return q
+}
+
+func TestFilterLikeEscape(t *testing.T) {
+	q := testFilterQuery()
+	tests := []struct {
+		typ   string
+		value string
+	}{
+		{`contains`, `%50!%!_off!!%`},
+		{`startswith`, `50!%!_off!!%`},
+		{`endswith`, `%50!%!_off!!`},
+	}
+	for _, test := range tests {
+		cond, values, e := q.buildFilter(`title`, json.Object{`value`: `50%_off!`, `type`: test.typ})
+		if e != nil {
+			t.Fatal(e)
+		}
+		if cond != `b.title LIKE ? ESCAPE '!'` {
+			t.Errorf(`%s: unexpected condition %s`, test.typ, cond)
+		}
+		if !reflect.DeepEqual(values, []interface{}{test.value}) {
+			t.Errorf(`%s: expected value %s, got %v`, test.typ, test.value, values)
+		}
+	}
+}
+
+func TestFilterOperators(t *testing.T) {
+	q := testFilterQuery()
+	tests := []struct {
+		name   string
+		filter json.Object
+		cond   string
+		values []interface{}
+	}{
+		{`author`, json.Object{`value`: `john`}, `b.author = ?`, []interface{}{`john`}},
+		{`price`, json.Object{`value`: []interface{}{10.0, 20.0}, `type`: `between`}, `b.price BETWEEN ? AND ?`, []interface{}{10.0, 20.0}},
+		{`price`, json.Object{`value`: `1, 2,3`, `type`: `not in`}, `b.price NOT IN (?,?,?)`, []interface{}{`1`, `2`, `3`}},
+		{`deleted`, json.Object{`type`: `is null`}, `b.deleted_at IS NULL`, nil},
+	}
+	for _, test := range tests {
+		cond, values, e := q.buildFilter(test.name, test.filter)
+		if e != nil {
+			t.Errorf(`%s: %s`, test.name, e)
+			continue
+		}
+		if cond != test.cond || !reflect.DeepEqual(values, test.values) {
+			t.Errorf(`%s: expected %s %v, got %s %v`, test.name, test.cond, test.values, cond, values)
+		}
+	}
+}
+
+func TestFilterWhitelist(t *testing.T) {
+	q := testFilterQuery()
+	tests := []struct {
+		name   string
+		filter json.Object
+	}{
+		{`isbn`, json.Object{`value`: `123`}},
+		{`title`, json.Object{`value`: `go`, `type`: `in`}},
+		{`author`, json.Object{`value`: `john`, `type`: `regexp`}},
+		{`price`, json.Object{`value`: []interface{}{10.0}, `type`: `between`}},
+		{`price`, json.Object{`value`: []interface{}{}, `type`: `in`}},
+	}
+	for _, test := range tests {
+		if _, _, e := q.buildFilter(test.name, test.filter); e == nil {
+			t.Errorf(`%s %v: expected error`, test.name, test.filter)
+		}
+	}
+}
+
+func TestFilterOrGroup(t *testing.T) {
+	q := testFilterQuery()
+	item := json.Object{`or`: []interface{}{
+		map[string]interface{}{`name`: `author`, `value`: `john`},
+		map[string]interface{}{`and`: []interface{}{
+			map[string]interface{}{`name`: `title`, `value`: `go`, `type`: `startswith`},
+			map[string]interface{}{`name`: `deleted`, `type`: `null`},
+		}},
+	}}
+	cond, values, e := q.buildFilterItem(item)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if expected := `(b.author = ? OR (b.title LIKE ? ESCAPE '!' AND b.deleted_at IS NULL))`; cond != expected {
+		t.Errorf(`expected %s, got %s`, expected, cond)
+	}
+	if !reflect.DeepEqual(values, []interface{}{`john`, `go%`}) {
+		t.Errorf(`unexpected values %v`, values)
+	}
+	if _, _, e := q.buildFilterItem(json.Object{`or`: []interface{}{}}); e == nil {
+		t.Error(`expected error for empty group`)
+	}
+}
+
+func TestDateRangeTimezone(t *testing.T) {
+	start, end, e := dateRange(`2024-03-01`, `2024-03-02`, `Asia/Jakarta`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if start != `2024-02-29 17:00:00` || end != `2024-03-02 17:00:00` {
+		t.Errorf(`unexpected range %s - %s`, start, end)
+	}
+}
+
+func TestQueryActionFilterSQL(t *testing.T) {
+	s := newTestServer()
+	cn, db := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	s.Post(`/books`).AddQueryAction(`SELECT * FROM books b`, ``).
+		Filter(`title`, `b.title`, `contains`).
+		Filter(`author`, `b.author`).
+		Sort(`created`, `b.created_at`).
+		MaxPageSize(50)
+	client := serveTest(t, s)
+
+	resp := doJSON(t, client, MethodPost, `/books`, `{"filters": [
+		{"name": "title", "value": "100%", "type": "contains"},
+		{"or": [{"name": "author", "value": "john"}, {"name": "author", "value": "jane"}]}
+	], "sort": {"active": "created", "direction": "desc"}, "page": {"offset": 20, "count": 10}}`)
+	if resp.StatusCode() != StatusOK {
+		t.Fatalf(`expected status 200, got %d: %s`, resp.StatusCode(), resp.Body())
+	}
+	query, args := db.last(`SELECT`)
+	if expected := `SELECT * FROM books b WHERE b.title LIKE ? ESCAPE '!' AND (b.author = ? OR b.author = ?) ORDER BY b.created_at DESC LIMIT 20, 10`; query != expected {
+		t.Errorf(`expected query %s, got %s`, expected, query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{`%100!%%`, `john`, `jane`}) {
+		t.Errorf(`unexpected values %v`, args)
+	}
+
+	for _, body := range []string{
+		`{"filters": [{"name": "isbn", "value": "1"}]}`,
+		`{"filters": [{"name": "title", "value": "go", "type": "ieq"}]}`,
+		`{"sort": {"active": "price"}}`,
+		`{"page": {"count": 51}}`,
+	} {
+		if resp := doJSON(t, client, MethodPost, `/books`, body); resp.StatusCode() != StatusBadRequest {
+			t.Errorf(`%s: expected status 400, got %d`, body, resp.StatusCode())
+		}
+	}
+}
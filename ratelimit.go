@@ -0,0 +1,191 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitStore token bucket storage, implement it to share limits between servers
+type RateLimitStore interface {
+	// Take consume one token of bucket key holding limit tokens refilled evenly over window. Return whether request allowed, remaining tokens and duration until bucket full.
+	Take(key string, limit int, window time.Duration) (bool, int, time.Duration, error)
+}
+
+// RateLimitKey return key of request to be limited, empty key means request not limited
+type RateLimitKey func(ctx *Context) string
+
+// rateLimitError request rejected by rate limiter, expected under load so not logged as warning
+type rateLimitError struct {
+	msg string
+}
+
+func (e *rateLimitError) Error() string {
+	return e.msg
+}
+
+// RateLimitOptions options of rate limiter, Limit requests per Window for each key
+type RateLimitOptions struct {
+	Limit  int
+	Window time.Duration
+	// Key default to RateLimitByIP
+	Key RateLimitKey
+	// Store default to in-memory store
+	Store RateLimitStore
+}
+
+type rateLimiter struct {
+	opt RateLimitOptions
+	// prefix separate buckets of limiters sharing the same store
+	prefix string
+}
+
+var rateLimiterCount uint64
+
+// RateLimitByIP limit by remote IP
+func RateLimitByIP() RateLimitKey {
+	return func(ctx *Context) string {
+		return `ip:` + ctx.RemoteIP()
+	}
+}
+
+// RateLimitBySession limit by session value, ex: user_id, fallback to remote IP if session value empty
+func RateLimitBySession(name string) RateLimitKey {
+	return func(ctx *Context) string {
+		if val := ctx.Session().GetString(name); val != `` {
+			return `session:` + val
+		}
+		return `ip:` + ctx.RemoteIP()
+	}
+}
+
+// RateLimitByHeader limit by request header value, ex: X-API-Key, fallback to remote IP if header not exists
+func RateLimitByHeader(name string) RateLimitKey {
+	return func(ctx *Context) string {
+		if val := ctx.req.Header().Get(name); val != `` {
+			return `header:` + val
+		}
+		return `ip:` + ctx.RemoteIP()
+	}
+}
+
+// RateLimitMiddleware create middleware responding 429 when limit exceeded, with RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and Retry-After headers
+func RateLimitMiddleware(opt RateLimitOptions) func(*Context) error {
+	return newRateLimiter(opt).check
+}
+
+// AddRateLimit add rate limit middleware for routes of group
+func (g *Group) AddRateLimit(opt RateLimitOptions) Middleware {
+	return g.AddMiddleware(RateLimitMiddleware(opt))
+}
+
+// AddRateLimit add rate limit middleware for all routes
+func (s *Server) AddRateLimit(opt RateLimitOptions) Middleware {
+	return s.defGroup().AddRateLimit(opt)
+}
+
+// RateLimit limit requests of route, checked after middlewares
+func (r *Route) RateLimit(opt RateLimitOptions) *Route {
+	r.limiter = newRateLimiter(opt)
+	return r
+}
+
+func newRateLimiter(opt RateLimitOptions) *rateLimiter {
+	if opt.Key == nil {
+		opt.Key = RateLimitByIP()
+	}
+	if opt.Store == nil {
+		opt.Store = NewMemoryRateLimitStore()
+	}
+	if opt.Window <= 0 {
+		opt.Window = time.Minute
+	}
+	prefix := strconv.FormatUint(atomic.AddUint64(&rateLimiterCount, 1), 10) + `:`
+	return &rateLimiter{opt: opt, prefix: prefix}
+}
+
+func (l *rateLimiter) check(ctx *Context) error {
+	key := l.opt.Key(ctx)
+	if key == `` || l.opt.Limit <= 0 {
+		return nil
+	}
+	allowed, remaining, reset, e := l.opt.Store.Take(l.prefix+key, l.opt.Limit, l.opt.Window)
+	if e != nil { //store unavailable, allow request
+		ctx.s.logger.W(`rate limit store error:`, e)
+		return nil
+	}
+	header := ctx.resp.Header()
+	header.Set(`RateLimit-Limit`, strconv.Itoa(l.opt.Limit))
+	header.Set(`RateLimit-Remaining`, strconv.Itoa(remaining))
+	header.Set(`RateLimit-Reset`, strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+	if allowed {
+		return nil
+	}
+	retry := l.opt.Window / time.Duration(l.opt.Limit)
+	header.Set(`Retry-After`, strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+	msg := fmt.Sprintf(`rate limit exceeded, retry after %d seconds`, int(math.Ceil(retry.Seconds())))
+	ctx.httpError(StatusTooManyRequests, StatusTooManyRequests, msg)
+	return &rateLimitError{msg: msg}
+}
+
+type tokenBucket struct {
+	tokens  float64
+	limit   float64
+	rate    float64
+	updated time.Time
+}
+
+// refill add tokens accumulated since last update
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.limit, b.tokens+now.Sub(b.updated).Seconds()*b.rate)
+	b.updated = now
+}
+
+type memoryRateLimitStore struct {
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+	sweepAt time.Time
+}
+
+// NewMemoryRateLimitStore create in-memory token bucket store
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (m *memoryRateLimitStore) Take(key string, limit int, window time.Duration) (bool, int, time.Duration, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := time.Now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit), updated: now}
+		m.buckets[key] = b
+	}
+	b.limit = float64(limit)
+	b.rate = float64(limit) / window.Seconds()
+	b.refill(now)
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	reset := time.Duration((b.limit - b.tokens) / b.rate * float64(time.Second))
+	return allowed, int(b.tokens), reset, nil
+}
+
+// sweep remove full buckets at most once a minute
+func (m *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Before(m.sweepAt) {
+		return
+	}
+	m.sweepAt = now.Add(time.Minute)
+	for key, b := range m.buckets {
+		if b.refill(now); b.tokens >= b.limit {
+			delete(m.buckets, key)
+		}
+	}
+}
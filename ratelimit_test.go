@@ -0,0 +1,72 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimitRoute(t *testing.T) {
+	s := newTestServer()
+	s.Get(`/items`).RateLimit(RateLimitOptions{Limit: 2, Window: time.Hour}).AddAction(func(*Context) error { return nil })
+	client := serveTest(t, s)
+
+	for i, remaining := range []string{`1`, `0`} {
+		resp := doTest(t, client, MethodGet, `/items`, nil)
+		if resp.StatusCode() != StatusOK {
+			t.Fatalf(`request %d: expected status 200, got %d`, i+1, resp.StatusCode())
+		}
+		if limit := string(resp.Header.Peek(`RateLimit-Limit`)); limit != `2` {
+			t.Errorf(`request %d: expected RateLimit-Limit 2, got %q`, i+1, limit)
+		}
+		if val := string(resp.Header.Peek(`RateLimit-Remaining`)); val != remaining {
+			t.Errorf(`request %d: expected RateLimit-Remaining %s, got %q`, i+1, remaining, val)
+		}
+	}
+	resp := doTest(t, client, MethodGet, `/items`, nil)
+	if resp.StatusCode() != StatusTooManyRequests {
+		t.Fatalf(`expected status 429, got %d`, resp.StatusCode())
+	}
+	if retry := string(resp.Header.Peek(`Retry-After`)); retry != `1800` {
+		t.Errorf(`expected Retry-After 1800, got %q`, retry)
+	}
+	if reset := string(resp.Header.Peek(`RateLimit-Reset`)); reset != `3600` {
+		t.Errorf(`expected RateLimit-Reset 3600, got %q`, reset)
+	}
+}
+
+func TestRateLimitByHeader(t *testing.T) {
+	s := newTestServer()
+	s.AddRateLimit(RateLimitOptions{Limit: 1, Window: time.Hour, Key: RateLimitByHeader(`X-API-Key`)})
+	s.Get(`/items`).AddAction(func(*Context) error { return nil })
+	client := serveTest(t, s)
+
+	keyA := map[string]string{`X-API-Key`: `a`}
+	if resp := doTest(t, client, MethodGet, `/items`, keyA); resp.StatusCode() != StatusOK {
+		t.Fatalf(`expected status 200, got %d`, resp.StatusCode())
+	}
+	if resp := doTest(t, client, MethodGet, `/items`, keyA); resp.StatusCode() != StatusTooManyRequests {
+		t.Errorf(`expected status 429 for second request of key a, got %d`, resp.StatusCode())
+	}
+	if resp := doTest(t, client, MethodGet, `/items`, map[string]string{`X-API-Key`: `b`}); resp.StatusCode() != StatusOK {
+		t.Errorf(`expected status 200 for key b, got %d`, resp.StatusCode())
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(key string, limit int, window time.Duration) (bool, int, time.Duration, error) {
+	return false, 0, 0, errors.New(`store unavailable`)
+}
+
+func TestRateLimitStoreErrorAllowRequest(t *testing.T) {
+	s := newTestServer()
+	s.Get(`/items`).RateLimit(RateLimitOptions{Limit: 1, Store: failingRateLimitStore{}}).AddAction(func(*Context) error { return nil })
+	client := serveTest(t, s)
+
+	for i := 0; i < 2; i++ {
+		if resp := doTest(t, client, MethodGet, `/items`, nil); resp.StatusCode() != StatusOK {
+			t.Errorf(`expected status 200 when store unavailable, got %d`, resp.StatusCode())
+		}
+	}
+}
@@ -11,6 +11,13 @@ func render(ctx *Context) bool {
 			msg := `Success`
 			pmsg = &msg
 		}
+		if pages := resp.renderedPages(); len(pages) == 1 {
+			for _, page := range pages {
+				data.Put(`page`, page)
+			}
+		} else if len(pages) > 1 {
+			data.Put(`pages`, pages)
+		}
 		data.Put(`status`, resp.StatusCode()).Put(`message`, *pmsg)
 		if len(ctx.debugLog) > 0 {
 			data.Put(`debug`, ctx.debugLog.Strings())
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"strings"
+
 	"github.com/eqto/go-json"
 	"github.com/valyala/fasthttp"
 )
@@ -9,6 +11,7 @@ type Response struct {
 	statusCode int
 	statusMsg  *string
 	data       json.Object
+	pages      map[string]json.Object
 
 	httpResp *fasthttp.Response
 	err      error
@@ -70,7 +73,7 @@ func (r *Response) Body() []byte {
 
 func (r *Response) streamWriter() Writer {
 	if r.writer == nil {
-		sw := &streamWriter{}
+		sw := newStreamWriter()
 		r.httpResp.SetBodyStreamWriter(sw.write)
 		r.writer = sw
 	}
@@ -81,9 +84,28 @@ func (r *Response) setBody(body []byte) {
 	r.httpResp.SetBody(body)
 }
 
+// setPage set page metadata of action assigned to property
+func (r *Response) setPage(property string, page json.Object) {
+	if r.pages == nil {
+		r.pages = make(map[string]json.Object)
+	}
+	r.pages[property] = page
+}
+
 func (r *Response) put(key string, value interface{}) {
 	if r.data == nil {
 		r.data = json.Object{}
 	}
 	r.data.Put(key, value)
 }
+
+// renderedPages page metadata of actions assigned to response property, actions assigned to $var not rendered
+func (r *Response) renderedPages() json.Object {
+	pages := json.Object{}
+	for property, page := range r.pages {
+		if property != `` && !strings.HasPrefix(property, `$`) {
+			pages[property] = page
+		}
+	}
+	return pages
+}
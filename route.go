@@ -1,13 +1,87 @@
 package api
 
-// Route ...
+import (
+	"fmt"
+
+	"github.com/eqto/go-json"
+)
+
+// Route built before Serve. Routes of running server are changed using LoadRoutes or RemoveRoute, Get, Post and others log error and return route that is not registered.
 type Route struct {
 	action []Action
 	secure bool
 	group  string
 
-	isWs   bool
+	ws     *Websocket
 	logger *logger
+
+	doc    routeDoc
+	fields []*Field
+	tx     txPolicy
+	dbName string
+	cache  *routeCache
+
+	limiter *rateLimiter
+}
+
+// UseDatabase use named connection added using Server.AddDatabase for query actions and Context.Tx
+func (r *Route) UseDatabase(name string) *Route {
+	r.dbName = name
+	return r
+}
+
+// Transaction set transaction policy of route, default TxReadWrite
+func (r *Route) Transaction(mode TxMode) *Route {
+	r.tx.mode = mode
+	return r
+}
+
+// Isolation set isolation level of route transaction, ex: IsolationSerializable. Only supported on sqlserver, Serve return error if route use mysql connection.
+func (r *Route) Isolation(level string) *Route {
+	r.tx.isolation = level
+	return r
+}
+
+// RetryOnDeadlock execute all actions again in a new transaction, up to budget times, when failed because of deadlock or serialization error. Ignored for TxNone route since its statements already committed.
+func (r *Route) RetryOnDeadlock(budget int) *Route {
+	r.tx.retry = budget
+	return r
+}
+
+// Validate validate request fields before executing actions, request with invalid fields responded with status 400 listing all failing fields
+func (r *Route) Validate(fields ...*Field) *Route {
+	r.fields = append(r.fields, fields...)
+	return r
+}
+
+// Summary set short summary of route for OpenAPI document
+func (r *Route) Summary(summary string) *Route {
+	r.doc.summary = summary
+	return r
+}
+
+// Description set description of route for OpenAPI document
+func (r *Route) Description(description string) *Route {
+	r.doc.description = description
+	return r
+}
+
+// Tags set OpenAPI tags of route, default to group name
+func (r *Route) Tags(tags ...string) *Route {
+	r.doc.tags = tags
+	return r
+}
+
+// RequestSchema set JSON schema of request body for OpenAPI document, ex: {"type": "object", "properties": {"name": {"type": "string"}}}
+func (r *Route) RequestSchema(schema json.Object) *Route {
+	r.doc.request = schema
+	return r
+}
+
+// ResponseSchema set JSON schema of data property in response body for OpenAPI document
+func (r *Route) ResponseSchema(schema json.Object) *Route {
+	r.doc.response = schema
+	return r
 }
 
 // Secure ...
@@ -58,18 +132,77 @@ func (r *Route) execute(s *Server, ctx *Context) error {
 			}
 		}
 	}()
-	if r.isWs {
-		s.wsServ.Upgrade(ctx.fastCtx)
-	} else {
-		for _, action := range r.action {
+	if r.ws != nil {
+		return r.ws.upgrade(ctx)
+	}
+	if len(r.fields) > 0 {
+		if e := validateRequest(ctx, r.fields); e != nil {
+			return e
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		e := r.executeActions(ctx)
+		if e == nil || attempt >= r.tx.retry || r.tx.mode == TxNone || !(ctx.retryable || isRetryableErr(e)) {
+			return e
+		}
+		s.logger.W(fmt.Sprintf(`retrying transaction after deadlock, attempt %d: %s`, attempt+1, e))
+		ctx.resetForRetry()
+	}
+}
+
+func (r *Route) executeActions(ctx *Context) error {
+	branchTaken := false
+	for i := 0; i < len(r.action); i++ {
+		if e := r.action[i].flow().err; e != nil {
+			return e
+		}
+		if !actionEnabled(ctx, r.action[i].flow(), &branchTaken) {
+			continue
+		}
+		if r.action[i].flow().parallel {
+			actions := []Action{r.action[i]}
+			for i+1 < len(r.action) && r.action[i+1].flow().parallel {
+				i++
+				if e := r.action[i].flow().err; e != nil {
+					return e
+				}
+				if actionEnabled(ctx, r.action[i].flow(), &branchTaken) {
+					actions = append(actions, r.action[i])
+				}
+			}
+			if e := executeParallel(ctx, actions); e != nil {
+				return e
+			}
+		} else {
+			action := r.action[i]
 			ctx.property = action.property()
 			if e := action.execute(ctx); e != nil {
 				return e
 			}
-			if ctx.resp.stop {
-				return nil
-			}
+		}
+		if ctx.resp.stop {
+			return nil
 		}
 	}
 	return nil
 }
+
+// actionEnabled evaluate action condition, branchTaken track whether an action of current If chain executed
+func actionEnabled(ctx *Context, fl *actionFlow, branchTaken *bool) bool {
+	switch fl.branch {
+	case branchIf:
+		*branchTaken = fl.cond.eval(ctx)
+		return *branchTaken
+	case branchElseIf:
+		if *branchTaken || !fl.cond.eval(ctx) {
+			return false
+		}
+		*branchTaken = true
+	case branchElse:
+		if *branchTaken {
+			return false
+		}
+		*branchTaken = true
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package api
+
+// routeTable snapshot of routes and static files. Snapshot never modified after stored, changes applied to a copy and swapped atomically.
+type routeTable struct {
+	routers map[string]*router
+	files   []file
+}
+
+func newRouteTable() *routeTable {
+	t := &routeTable{routers: make(map[string]*router)}
+	for _, method := range methods {
+		t.routers[method] = &router{}
+	}
+	return t
+}
+
+func (t *routeTable) clone() *routeTable {
+	c := &routeTable{
+		routers: make(map[string]*router, len(t.routers)),
+		files:   append([]file{}, t.files...),
+	}
+	for method, rt := range t.routers {
+		c.routers[method] = rt.clone()
+	}
+	return c
+}
+
+func (t *routeTable) removeFile(path string) bool {
+	for i, file := range t.files {
+		if file.path == path {
+			t.files = append(t.files[:i], t.files[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// findRoute return route matching method and path, HEAD request fallback to GET route
+func (t *routeTable) findRoute(method, path string) (*Route, map[string]string) {
+	if rt, ok := t.routers[method]; ok {
+		if route, params := rt.find(path); route != nil {
+			return route, params
+		}
+	}
+	if method == MethodHead {
+		return t.findRoute(MethodGet, path)
+	}
+	return nil, nil
+}
+
+// allowedMethods return all methods having route matching path
+func (t *routeTable) allowedMethods(path string) []string {
+	allowed := []string{}
+	for _, method := range methods {
+		if route, _ := t.findRoute(method, path); route != nil {
+			allowed = append(allowed, method)
+		} else if method == MethodOptions && len(allowed) > 0 {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// routes return current snapshot of routes
+func (s *Server) routes() *routeTable {
+	return s.table.Load().(*routeTable)
+}
+
+// updateRoutes apply fn to a copy of current routes and swap it in, current routes kept if fn return error
+func (s *Server) updateRoutes(fn func(t *routeTable) error) error {
+	s.tableMu.Lock()
+	defer s.tableMu.Unlock()
+	t := s.routes().clone()
+	if e := fn(t); e != nil {
+		return e
+	}
+	s.table.Store(t)
+	return nil
+}
+
+// registerRoutes apply fn to current routes in place until server started serving, so registrations before Serve not copying whole table each time. After started fn applied to a copy like updateRoutes.
+func (s *Server) registerRoutes(fn func(t *routeTable) error) error {
+	s.tableMu.Lock()
+	defer s.tableMu.Unlock()
+	t := s.routes()
+	if s.tableServed {
+		t = t.clone()
+	}
+	if e := fn(t); e != nil {
+		return e
+	}
+	s.table.Store(t)
+	return nil
+}
+
+// serveRoutes check routes and mark them as read by request handler, changes applied to a copy from now on
+func (s *Server) serveRoutes() error {
+	s.tableMu.Lock()
+	defer s.tableMu.Unlock()
+	if e := checkActions(s.routes()); e != nil {
+		return e
+	}
+	if e := s.checkIsolation(s.routes()); e != nil {
+		return e
+	}
+	s.tableServed = true
+	return nil
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// router is a radix tree of path segments holding all routes of a single http method.
+// Supported segments:
+//
+//	/users          static segment
+//	/:id            named parameter, match any single segment
+//	/:id(\d+)       named parameter with regex constraint
+//	/*rest          catch-all, match the rest of the path, only allowed as last segment
+//
+// Static segments have priority over parameters, parameters have priority over catch-all.
+type router struct {
+	root *routeNode
+}
+
+type routeNode struct {
+	static   map[string]*routeNode
+	params   []*routeNode
+	catchAll *routeNode
+
+	segment string
+	name    string
+	regex   *regexp.Regexp
+	route   *Route
+}
+
+func (r *router) node(path string, create bool) (*routeNode, error) {
+	if r.root == nil {
+		if !create {
+			return nil, nil
+		}
+		r.root = &routeNode{}
+	}
+	n := r.root
+	segments := splitPath(path)
+	for i, seg := range segments {
+		var child *routeNode
+		switch {
+		case strings.HasPrefix(seg, `:`):
+			for _, p := range n.params {
+				if p.segment == seg {
+					child = p
+					break
+				}
+			}
+			if child == nil && create {
+				name, regex, e := parseParamSegment(seg)
+				if e != nil {
+					return nil, e
+				}
+				child = &routeNode{segment: seg, name: name, regex: regex}
+				n.params = append(n.params, child)
+			}
+		case strings.HasPrefix(seg, `*`):
+			if i < len(segments)-1 {
+				return nil, fmt.Errorf(`catch-all segment %s must be the last segment of path %s`, seg, path)
+			}
+			if n.catchAll != nil && n.catchAll.segment != seg {
+				return nil, fmt.Errorf(`conflicting catch-all segment %s with %s on path %s`, seg, n.catchAll.segment, path)
+			}
+			child = n.catchAll
+			if child == nil && create {
+				name := seg[1:]
+				if name == `` {
+					name = `*`
+				}
+				child = &routeNode{segment: seg, name: name}
+				n.catchAll = child
+			}
+		default:
+			child = n.static[seg]
+			if child == nil && create {
+				if n.static == nil {
+					n.static = make(map[string]*routeNode)
+				}
+				child = &routeNode{segment: seg}
+				n.static[seg] = child
+			}
+		}
+		if child == nil {
+			return nil, nil
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// add register route to path, return existing route if path already registered
+func (r *router) add(path string, route *Route) (*Route, error) {
+	n, e := r.node(path, true)
+	if e != nil {
+		return nil, e
+	}
+	if n.route == nil {
+		n.route = route
+	}
+	return n.route, nil
+}
+
+// get return route registered with exact path pattern
+func (r *router) get(path string) *Route {
+	if n, _ := r.node(path, false); n != nil {
+		return n.route
+	}
+	return nil
+}
+
+func (r *router) remove(path string) {
+	if n, _ := r.node(path, false); n != nil {
+		n.route = nil
+	}
+}
+
+// find return route matching request path and its path parameters
+func (r *router) find(path string) (*Route, map[string]string) {
+	if r.root == nil {
+		return nil, nil
+	}
+	params := make(map[string]string)
+	if route := r.root.match(splitPath(path), params); route != nil {
+		return route, params
+	}
+	return nil, nil
+}
+
+// walk call fn for each registered route ordered by path
+func (r *router) walk(fn func(path string, route *Route)) {
+	if r.root != nil {
+		r.root.walk(``, fn)
+	}
+}
+
+func (n *routeNode) walk(path string, fn func(path string, route *Route)) {
+	if n.route != nil {
+		fn(path, n.route)
+	}
+	keys := make([]string, 0, len(n.static))
+	for key := range n.static {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		n.static[key].walk(path+`/`+key, fn)
+	}
+	for _, child := range n.params {
+		child.walk(path+`/`+child.segment, fn)
+	}
+	if n.catchAll != nil {
+		n.catchAll.walk(path+`/`+n.catchAll.segment, fn)
+	}
+}
+
+func (n *routeNode) match(segments []string, params map[string]string) *Route {
+	if len(segments) == 0 {
+		return n.route
+	}
+	seg := segments[0]
+	if child, ok := n.static[seg]; ok {
+		if route := child.match(segments[1:], params); route != nil {
+			return route
+		}
+	}
+	for _, child := range n.params {
+		if child.regex != nil && !child.regex.MatchString(seg) {
+			continue
+		}
+		if route := child.match(segments[1:], params); route != nil {
+			params[child.name] = seg
+			return route
+		}
+	}
+	if n.catchAll != nil && n.catchAll.route != nil {
+		params[n.catchAll.name] = strings.Join(segments, `/`)
+		return n.catchAll.route
+	}
+	return nil
+}
+
+func parseParamSegment(seg string) (string, *regexp.Regexp, error) {
+	name := seg[1:]
+	idx := strings.Index(name, `(`)
+	if idx < 0 {
+		if name == `` {
+			return ``, nil, errors.New(`empty parameter name`)
+		}
+		return name, nil, nil
+	}
+	if !strings.HasSuffix(name, `)`) {
+		return ``, nil, fmt.Errorf(`invalid parameter segment %s`, seg)
+	}
+	pattern := name[idx+1 : len(name)-1]
+	name = name[:idx]
+	if name == `` {
+		return ``, nil, fmt.Errorf(`empty parameter name on segment %s`, seg)
+	}
+	regex, e := regexp.Compile(`^(?:` + pattern + `)$`)
+	if e != nil {
+		return ``, nil, e
+	}
+	return name, regex, nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, `/`), `/`)
+}
+
+func (r *router) clone() *router {
+	if r.root == nil {
+		return &router{}
+	}
+	return &router{root: r.root.clone()}
+}
+
+// clone copy nodes, routes shared since registered route not modified after serving
+func (n *routeNode) clone() *routeNode {
+	c := *n
+	if n.static != nil {
+		c.static = make(map[string]*routeNode, len(n.static))
+		for seg, child := range n.static {
+			c.static[seg] = child.clone()
+		}
+	}
+	if n.params != nil {
+		c.params = make([]*routeNode, len(n.params))
+		for i, child := range n.params {
+			c.params[i] = child.clone()
+		}
+	}
+	if n.catchAll != nil {
+		c.catchAll = n.catchAll.clone()
+	}
+	return &c
+}
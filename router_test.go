@@ -0,0 +1,53 @@
+package api
+
+import "testing"
+
+func TestRouterFind(t *testing.T) {
+	r := &router{}
+	for _, path := range []string{`/users`, `/users/me`, `/users/:id(\d+)`, `/users/:id/orders/:orderId`, `/files/*rest`} {
+		if _, e := r.add(path, &Route{doc: routeDoc{summary: path}}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	tests := []struct {
+		path    string
+		pattern string
+		params  map[string]string
+	}{
+		{`/users`, `/users`, map[string]string{}},
+		{`/users/me`, `/users/me`, map[string]string{}},
+		{`/users/42`, `/users/:id(\d+)`, map[string]string{`id`: `42`}},
+		{`/users/abc`, ``, nil},
+		{`/users/7/orders/9`, `/users/:id/orders/:orderId`, map[string]string{`id`: `7`, `orderId`: `9`}},
+		{`/files/a/b.txt`, `/files/*rest`, map[string]string{`rest`: `a/b.txt`}},
+		{`/unknown`, ``, nil},
+	}
+	for _, test := range tests {
+		route, params := r.find(test.path)
+		if test.pattern == `` {
+			if route != nil {
+				t.Errorf(`%s: expected no route, got %s`, test.path, route.doc.summary)
+			}
+			continue
+		}
+		if route == nil || route.doc.summary != test.pattern {
+			t.Errorf(`%s: expected route %s, got %v`, test.path, test.pattern, route)
+			continue
+		}
+		if len(params) != len(test.params) {
+			t.Errorf(`%s: expected params %v, got %v`, test.path, test.params, params)
+		}
+		for key, val := range test.params {
+			if params[key] != val {
+				t.Errorf(`%s: expected param %s = %s, got %s`, test.path, key, val, params[key])
+			}
+		}
+	}
+}
+
+func TestRouterRejectCatchAllNotLast(t *testing.T) {
+	r := &router{}
+	if _, e := r.add(`/files/*rest/edit`, &Route{}); e == nil {
+		t.Error(`expected error for catch-all segment not last`)
+	}
+}
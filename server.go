@@ -1,10 +1,16 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/eqto/api-server/websocket"
@@ -16,18 +22,36 @@ import (
 type ServerOptions func(*Server)
 
 type Server struct {
-	serv   *fasthttp.Server
-	wsServ *websocket.Server
+	serv         *fasthttp.Server
+	ln           *shutdownListener
+	redirectServ *fasthttp.Server
+	wsServs      []*websocket.Server
 
-	routeMap map[string]map[string]*Route
-	proxies  []*Proxy
-	files    []file
+	table       atomic.Value // *routeTable
+	tableMu     sync.Mutex   // guard table updates and groupMap
+	tableServed bool
+	proxies     []*Proxy
 
 	normalize   bool
 	cn          *dbm.Connection
+	databases   map[string]*database
+	dbLock      sync.RWMutex // guard cn and databases, read by request handler
 	dbConnected bool
 	middlewares []*middlewareContainer
 	render      Render
+	sessions    *sessionManager
+	cacheStore  CacheStore
+	corsMap     map[string]*corsPolicy
+	certs       *certStore
+	clientCAs   *x509.CertPool
+	clientAuth  tls.ClientAuthType
+	network     string
+	ready       int32
+	drainDelay  time.Duration
+	onStart     []func() error
+	onShutdown  []func(ctx context.Context) error
+	streams     map[*streamWriter]struct{}
+	streamLock  sync.Mutex
 	options     []ServerOptions
 	timeout     time.Duration
 
@@ -40,11 +64,43 @@ type Server struct {
 
 // Database ...
 func (s *Server) Database() *dbm.Connection {
+	s.dbLock.RLock()
+	defer s.dbLock.RUnlock()
 	return s.cn
 }
 
 func (s *Server) SetDatabase(cn *dbm.Connection) {
-	s.cn = cn
+	s.AddDatabase(``, cn)
+}
+
+// SetSessionStore persist session values using store, session id sent to client as cookie signed with secret
+func (s *Server) SetSessionStore(store SessionStore, secret string) {
+	if s.sessions == nil {
+		s.sessions = &sessionManager{cookie: `session_id`, idle: 30 * time.Minute}
+	}
+	s.sessions.store = store
+	s.sessions.secret = []byte(secret)
+}
+
+// SetSessionTimeout set idle timeout (session expired after no activity) and absolute timeout (session expired after created regardless activity). Zero absolute means no absolute timeout. Must be called after SetSessionStore.
+func (s *Server) SetSessionTimeout(idle, absolute time.Duration) {
+	if s.sessions == nil {
+		s.logger.W(`session store not set`)
+		return
+	}
+	if idle > 0 {
+		s.sessions.idle = idle
+	}
+	s.sessions.absolute = absolute
+}
+
+// SetSessionCookie set cookie name for session id, default session_id. Must be called after SetSessionStore.
+func (s *Server) SetSessionCookie(name string) {
+	if s.sessions == nil {
+		s.logger.W(`session store not set`)
+		return
+	}
+	s.sessions.cookie = name
 }
 
 func (s *Server) MaxRequestSize(size int) {
@@ -58,14 +114,14 @@ func (s *Server) OpenDatabase(driver, host string, port int, username, password,
 	if e != nil {
 		return e
 	}
-	s.cn = cn
+	s.AddDatabase(``, cn)
 	s.dbConnected = true
 	return nil
 }
 
 // Connect ...
 func (s *Server) Connect() error {
-	if e := s.cn.Connect(); e != nil {
+	if e := s.Database().Connect(); e != nil {
 		return e
 	}
 	s.dbConnected = true
@@ -93,19 +149,21 @@ func (s *Server) FileRoute(path, dest, redirectTo string) error {
 	if e != nil {
 		return e
 	}
-	s.files = append(s.files, f)
-	return nil
+	return s.registerRoutes(func(t *routeTable) error {
+		t.files = append(t.files, f)
+		return nil
+	})
 }
 
 // FileRouteRemove ...
 func (s *Server) FileRouteRemove(path string) error {
-	for i, file := range s.files {
-		if file.path == path {
-			s.files = append(s.files[:i], s.files[i+1:]...)
-			return nil
-		}
+	if !strings.HasPrefix(path, `^`) {
+		path = `^` + path
 	}
-	return nil
+	return s.updateRoutes(func(t *routeTable) error {
+		t.removeFile(path)
+		return nil
+	})
 }
 
 func (s *Server) Post(path string) *Route {
@@ -128,50 +186,148 @@ func (s *Server) GetAction(f func(*Context) error) *Route {
 	return s.defGroup().GetAction(f)
 }
 
+func (s *Server) GetSecureAction(f func(*Context) error) *Route {
+	return s.defGroup().GetSecureAction(f)
+}
+
+func (s *Server) Put(path string) *Route {
+	return s.defGroup().Put(path)
+}
+
+func (s *Server) PutAction(f func(*Context) error) *Route {
+	return s.defGroup().PutAction(f)
+}
+
+func (s *Server) PutSecureAction(f func(*Context) error) *Route {
+	return s.defGroup().PutSecureAction(f)
+}
+
+func (s *Server) Patch(path string) *Route {
+	return s.defGroup().Patch(path)
+}
+
+func (s *Server) PatchAction(f func(*Context) error) *Route {
+	return s.defGroup().PatchAction(f)
+}
+
+func (s *Server) PatchSecureAction(f func(*Context) error) *Route {
+	return s.defGroup().PatchSecureAction(f)
+}
+
+func (s *Server) Delete(path string) *Route {
+	return s.defGroup().Delete(path)
+}
+
+func (s *Server) DeleteAction(f func(*Context) error) *Route {
+	return s.defGroup().DeleteAction(f)
+}
+
+func (s *Server) DeleteSecureAction(f func(*Context) error) *Route {
+	return s.defGroup().DeleteSecureAction(f)
+}
+
+func (s *Server) Head(path string) *Route {
+	return s.defGroup().Head(path)
+}
+
+func (s *Server) HeadAction(f func(*Context) error) *Route {
+	return s.defGroup().HeadAction(f)
+}
+
+func (s *Server) HeadSecureAction(f func(*Context) error) *Route {
+	return s.defGroup().HeadSecureAction(f)
+}
+
+func (s *Server) Options(path string) *Route {
+	return s.defGroup().Options(path)
+}
+
+func (s *Server) OptionsAction(f func(*Context) error) *Route {
+	return s.defGroup().OptionsAction(f)
+}
+
+func (s *Server) OptionsSecureAction(f func(*Context) error) *Route {
+	return s.defGroup().OptionsSecureAction(f)
+}
+
 // NormalizeFunc this func need to called before adding any routes. parameter n=true for renaming all route paths to lowercase, separated with underscore. Ex: /HelloWorld registered as /hello_world
 func (s *Server) NormalizeFunc(n bool) {
 	s.normalize = n
 }
 
 func (s *Server) RemoveRoute(method, path string) {
-	delete(s.routeMap[method], path)
-}
-
-func (s *Server) executeRoutes(ctx *Context, path string) bool {
-	if route, ok := s.routeMap[ctx.Method()][path]; ok {
-		for _, m := range s.middlewares {
-			if m.group == `` || m.group == route.group {
-				if !m.secure || (m.secure && route.secure) {
-					if e := m.f(ctx); e != nil {
-						ctx.setErr(e)
-						if m.secure {
-							if ctx.resp.httpResp.StatusCode() == 200 {
-								ctx.StatusUnauthorized(`Authorization error: ` + e.Error())
-							}
+	s.defGroup().RemoveRoute(method, path)
+}
+
+func (s *Server) executeRoutes(ctx *Context, t *routeTable, path string) bool {
+	if s.preflight(ctx, t, path) {
+		return true
+	}
+	route, params := t.findRoute(ctx.Method(), path)
+	if route == nil {
+		if ctx.Method() == MethodOptions {
+			if allowed := t.allowedMethods(path); len(allowed) > 0 {
+				ctx.resp.Header().Set(`Allow`, strings.Join(allowed, `, `))
+				ctx.resp.httpResp.SetStatusCode(StatusNoContent)
+				ctx.resp.stop = true
+				return true
+			}
+		}
+		return false
+	}
+	if p, ok := s.setCORSHeaders(ctx, route.group); ok && len(p.opt.ExposeHeaders) > 0 {
+		ctx.resp.Header().Set(`Access-Control-Expose-Headers`, strings.Join(p.opt.ExposeHeaders, `, `))
+	}
+	ctx.params = params
+	ctx.tx = route.tx
+	ctx.dbName = route.dbName
+	for _, m := range s.middlewares {
+		if m.group == `` || m.group == route.group {
+			if !m.secure || (m.secure && route.secure) {
+				if e := m.f(ctx); e != nil {
+					ctx.setErr(e)
+					if m.secure {
+						if ctx.resp.httpResp.StatusCode() == 200 {
+							ctx.StatusUnauthorized(`Authorization error: ` + e.Error())
+						}
+					} else {
+						if _, ok := e.(*rateLimitError); ok {
+							s.logger.D(e)
 						} else {
 							s.logger.W(e)
-							if ctx.resp.httpResp.StatusCode() == 200 {
-								ctx.StatusInternalServerError(`Internal server error`)
-							}
 						}
-						return true
+						if ctx.resp.httpResp.StatusCode() == 200 {
+							ctx.StatusInternalServerError(`Internal server error`)
+						}
 					}
+					return true
 				}
 			}
 		}
-		httpResp := ctx.resp.httpResp
-
-		e := route.execute(s, ctx)
-
-		if e != nil {
+	}
+	if route.limiter != nil {
+		if e := route.limiter.check(ctx); e != nil {
 			ctx.setErr(e)
-		} else if !httpResp.IsBodyStream() && ctx.resp.data == nil && len(httpResp.Body()) == 0 {
-			ctx.resp.data = json.Object{}
+			return true
 		}
-		ctx.closeTx()
+	}
+	if s.loadCache(ctx, route) {
 		return true
 	}
-	return false
+	httpResp := ctx.resp.httpResp
+
+	e := route.execute(s, ctx)
+
+	if e != nil {
+		ctx.setErr(e)
+	} else if !httpResp.IsBodyStream() && ctx.resp.data == nil && len(httpResp.Body()) == 0 {
+		ctx.resp.data = json.Object{}
+	}
+	ctx.closeTx()
+	if ctx.resp.err == nil && len(ctx.invalidateTags) > 0 {
+		s.InvalidateCache(ctx.invalidateTags...)
+	}
+	return true
 }
 
 func (s *Server) executeProxies(ctx *Context, fastCtx *fasthttp.RequestCtx, path string) bool {
@@ -187,8 +343,8 @@ func (s *Server) executeProxies(ctx *Context, fastCtx *fasthttp.RequestCtx, path
 	return false
 }
 
-func (s *Server) executeFiles(fastCtx *fasthttp.RequestCtx, path string) bool {
-	for _, file := range s.files {
+func (s *Server) executeFiles(fastCtx *fasthttp.RequestCtx, t *routeTable, path string) bool {
+	for _, file := range t.files {
 		if file.match(string(path)) {
 			file.handler(fastCtx)
 			return true
@@ -207,18 +363,30 @@ func (s *Server) serve(ln net.Listener) error {
 		}
 
 		path := ctx.URL().Path
+		// snapshot of routes, request keep using it even if routes replaced while in-flight
+		t := s.routes()
 
 		ctx.resp.SetContentType(`application/json`)
 
-		if ok := s.executeRoutes(ctx, path); !ok {
-			if ok := s.executeFiles(fastCtx, path); !ok {
+		if ok := s.executeRoutes(ctx, t, path); !ok {
+			if ok := s.executeFiles(fastCtx, t, path); !ok {
 				if ok := s.executeProxies(ctx, fastCtx, path); !ok {
-					errStr := fmt.Sprintf(`route %s %s not found`, ctx.Method(), path)
-					ctx.setErr(errors.New(errStr))
-					ctx.StatusServiceUnavailable(errStr)
+					if allowed := t.allowedMethods(path); len(allowed) > 0 {
+						ctx.resp.Header().Set(`Allow`, strings.Join(allowed, `, `))
+						errStr := fmt.Sprintf(`method %s not allowed for route %s`, ctx.Method(), path)
+						ctx.setErr(errors.New(errStr))
+						ctx.StatusMethodNotAllowed(errStr)
+					} else {
+						errStr := fmt.Sprintf(`route %s %s not found`, ctx.Method(), path)
+						ctx.setErr(errors.New(errStr))
+						ctx.StatusServiceUnavailable(errStr)
+					}
 				}
 			}
 		}
+		if s.sessions != nil {
+			s.sessions.save(ctx)
+		}
 		renderOk := false
 		if s.render != nil {
 			renderOk = s.render(ctx)
@@ -226,15 +394,21 @@ func (s *Server) serve(ln net.Listener) error {
 		if !renderOk {
 			render(ctx)
 		}
+		if ctx.cache != nil {
+			ctx.cache.finish(ctx)
+		}
 	}
 	if s.serv != nil {
-		if e := s.Shutdown(); e != nil {
+		if e := s.shutdownServer(context.Background()); e != nil {
 			return e
 		}
 	}
 	for _, opt := range s.options {
 		opt(s)
 	}
+	if e := s.serveRoutes(); e != nil {
+		return e
+	}
 	timeout := s.timeout
 	if timeout == 0 {
 		timeout = 60 * time.Second
@@ -255,7 +429,14 @@ func (s *Server) serve(ln net.Listener) error {
 	if s.maxRequestSize > 0 {
 		s.serv.MaxRequestBodySize = s.maxRequestSize
 	}
-	return s.serv.Serve(ln)
+	for _, fn := range s.onStart {
+		if e := fn(); e != nil {
+			return e
+		}
+	}
+	s.ln = &shutdownListener{Listener: ln}
+	atomic.StoreInt32(&s.ready, 1)
+	return s.serv.Serve(s.ln)
 }
 
 func (s *Server) ServeUnix(filename string) error {
@@ -270,7 +451,7 @@ func (s *Server) ServeUnix(filename string) error {
 
 // Serve ..
 func (s *Server) Serve(port int) error {
-	ln, e := net.Listen(`tcp4`, fmt.Sprintf(`:%d`, port))
+	ln, e := s.listen(port)
 	if e != nil {
 		return e
 	}
@@ -279,8 +460,9 @@ func (s *Server) Serve(port int) error {
 	return s.serve(ln)
 }
 
-// Shutdown ..
+// Shutdown stop HTTP servers waiting in-flight requests, database connections, websockets and OnShutdown funcs untouched. Use ShutdownContext for full graceful shutdown.
 func (s *Server) Shutdown() error {
+	atomic.StoreInt32(&s.ready, 0)
 	if s.serv != nil {
 		s.serv.DisableKeepalive = true
 		if e := s.serv.Shutdown(); e != nil {
@@ -288,6 +470,12 @@ func (s *Server) Shutdown() error {
 		}
 	}
 	s.serv = nil
+	if s.redirectServ != nil {
+		if e := s.redirectServ.Shutdown(); e != nil {
+			return e
+		}
+		s.redirectServ = nil
+	}
 	return nil
 }
 
@@ -298,6 +486,13 @@ func (s *Server) SetLogger(debug func(...interface{}), info func(...interface{})
 
 // if name is empty will return default group
 func (s *Server) Group(name string) *Group {
+	s.tableMu.Lock()
+	defer s.tableMu.Unlock()
+	return s.group(name)
+}
+
+// group caller must hold tableMu
+func (s *Server) group(name string) *Group {
 	g := &Group{s: s, name: name}
 	if name != `` {
 		g.SetPrefixPath(name)
@@ -310,17 +505,13 @@ func (s *Server) Group(name string) *Group {
 }
 
 func (s *Server) HasGroup(name string) bool {
-	if s.groupMap == nil {
-		return false
-	}
+	s.tableMu.Lock()
+	defer s.tableMu.Unlock()
 	_, ok := s.groupMap[name]
 	return ok
 }
 
 func (s *Server) defGroup() *Group {
-	if s.stdGroup == nil {
-		s.stdGroup = s.Group(``)
-	}
 	return s.stdGroup
 }
 
@@ -331,12 +522,11 @@ func (s *Server) HandleWebsocket(path string) *Websocket {
 // New ...
 func New(opts ...ServerOptions) *Server {
 	s := &Server{
-		routeMap: make(map[string]map[string]*Route),
-		options:  opts,
+		options:    opts,
+		cacheStore: NewMemoryCacheStore(1000),
 	}
 	s.SetLogger(log.Println, log.Println, log.Println, log.Println)
-
-	s.routeMap[MethodGet] = make(map[string]*Route)
-	s.routeMap[MethodPost] = make(map[string]*Route)
+	s.table.Store(newRouteTable())
+	s.stdGroup = &Group{s: s}
 	return s
 }
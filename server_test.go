@@ -0,0 +1,149 @@
+package api
+
+import (
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func newTestContext(t *testing.T, s *Server, method, uri string, headers map[string]string) *Context {
+	t.Helper()
+	fastCtx := &fasthttp.RequestCtx{}
+	fastCtx.Request.Header.SetMethod(method)
+	fastCtx.Request.SetRequestURI(uri)
+	for key, val := range headers {
+		fastCtx.Request.Header.Set(key, val)
+	}
+	ctx, e := newContext(s, fastCtx)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return ctx
+}
+
+func responseHeader(ctx *Context, key string) string {
+	return string(ctx.resp.httpResp.Header.Peek(key))
+}
+
+// newTestServer server without logging
+func newTestServer() *Server {
+	s := New()
+	nop := func(...interface{}) {}
+	s.SetLogger(nop, nop, nop, nop)
+	return s
+}
+
+// serveTest serve s on in-memory listener, server shut down when test finished
+func serveTest(t *testing.T, s *Server) *fasthttp.Client {
+	t.Helper()
+	ln := serveTestListener(t, s)
+	return &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+}
+
+// serveTestListener serve s on in-memory listener and return it to dial raw connections
+func serveTestListener(t *testing.T, s *Server) *fasthttputil.InmemoryListener {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	go s.serve(ln)
+	t.Cleanup(func() {
+		s.Shutdown()
+		ln.Close()
+	})
+	return ln
+}
+
+func doTest(t *testing.T, client *fasthttp.Client, method, uri string, headers map[string]string) *fasthttp.Response {
+	t.Helper()
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(method)
+	req.SetRequestURI(`http://test` + uri)
+	for key, val := range headers {
+		req.Header.Set(key, val)
+	}
+	resp := &fasthttp.Response{}
+	if method == MethodHead {
+		resp.SkipBody = true
+	}
+	if e := client.Do(req, resp); e != nil {
+		t.Fatal(e)
+	}
+	return resp
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	s := newTestServer()
+	s.Get(`/items`).AddAction(func(*Context) error { return nil })
+	s.Delete(`/items/:id`).AddAction(func(*Context) error { return nil })
+	client := serveTest(t, s)
+
+	resp := doTest(t, client, MethodPost, `/items`, nil)
+	if resp.StatusCode() != StatusMethodNotAllowed {
+		t.Errorf(`expected status 405, got %d`, resp.StatusCode())
+	}
+	if allow := string(resp.Header.Peek(`Allow`)); allow != `GET, HEAD, OPTIONS` {
+		t.Errorf(`expected Allow "GET, HEAD, OPTIONS", got %q`, allow)
+	}
+	resp = doTest(t, client, MethodGet, `/items/1`, nil)
+	if allow := string(resp.Header.Peek(`Allow`)); resp.StatusCode() != StatusMethodNotAllowed || allow != `DELETE, OPTIONS` {
+		t.Errorf(`expected status 405 allowing "DELETE, OPTIONS", got %d %q`, resp.StatusCode(), allow)
+	}
+	if resp := doTest(t, client, MethodPost, `/unknown`, nil); resp.StatusCode() == StatusMethodNotAllowed {
+		t.Error(`unknown path responded with status 405`)
+	}
+}
+
+func TestOptionsAllow(t *testing.T) {
+	s := newTestServer()
+	s.Get(`/items`).AddAction(func(*Context) error { return nil })
+	s.Post(`/items`).AddAction(func(*Context) error { return nil })
+
+	resp := doTest(t, serveTest(t, s), MethodOptions, `/items`, nil)
+	if resp.StatusCode() != StatusNoContent {
+		t.Errorf(`expected status 204, got %d`, resp.StatusCode())
+	}
+	if allow := string(resp.Header.Peek(`Allow`)); allow != `GET, HEAD, POST, OPTIONS` {
+		t.Errorf(`expected Allow "GET, HEAD, POST, OPTIONS", got %q`, allow)
+	}
+}
+
+func TestHeadFallbackToGet(t *testing.T) {
+	s := newTestServer()
+	var method string
+	s.Get(`/items`).AddAction(func(ctx *Context) error {
+		method = ctx.Method()
+		return nil
+	})
+	s.Get(`/reports`).AddAction(func(*Context) error { return nil })
+	headCalled := false
+	s.Head(`/reports`).AddAction(func(*Context) error {
+		headCalled = true
+		return nil
+	})
+	client := serveTest(t, s)
+
+	if resp := doTest(t, client, MethodHead, `/items`, nil); resp.StatusCode() != StatusOK || method != MethodHead {
+		t.Errorf(`expected HEAD served by GET route, got status %d method %q`, resp.StatusCode(), method)
+	}
+	if resp := doTest(t, client, MethodHead, `/reports`, nil); resp.StatusCode() != StatusOK || !headCalled {
+		t.Errorf(`expected HEAD route used before GET route, got status %d`, resp.StatusCode())
+	}
+}
+
+// doJSON send request with JSON body
+func doJSON(t *testing.T, client *fasthttp.Client, method, uri, body string) *fasthttp.Response {
+	t.Helper()
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(method)
+	req.SetRequestURI(`http://test` + uri)
+	req.Header.SetContentType(`application/json`)
+	req.SetBodyString(body)
+	resp := &fasthttp.Response{}
+	if e := client.Do(req, resp); e != nil {
+		t.Fatal(e)
+	}
+	return resp
+}
@@ -1,14 +1,24 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 )
 
 type Session struct {
 	logger *logger
 	val    map[string]interface{}
+	// req values of current request only, never saved to store, take precedence over stored values
+	req map[string]interface{}
+
+	id        string
+	oldID     string
+	createdAt time.Time
+	loaded    bool
+	modified  bool
 }
 
 func (s *Session) init() {
@@ -17,24 +27,89 @@ func (s *Session) init() {
 	}
 }
 
+// clone copy of session for parallel action
+func (s *Session) clone() *Session {
+	c := *s
+	c.val = make(map[string]interface{}, len(s.val))
+	for key, val := range s.val {
+		c.val[key] = val
+	}
+	c.req = make(map[string]interface{}, len(s.req))
+	for key, val := range s.req {
+		c.req[key] = val
+	}
+	return &c
+}
+
+// ID return current session id, empty if session not yet saved to store
+func (s *Session) ID() string {
+	return s.id
+}
+
+// CreatedAt return time when session created
+func (s *Session) CreatedAt() time.Time {
+	return s.createdAt
+}
+
+// Regenerate assign new session id while keeping session values, old session will be destroyed from store. Call this after privilege changes (ex. login) to prevent session fixation.
+func (s *Session) Regenerate() {
+	if s.oldID == `` {
+		s.oldID = s.id
+	}
+	s.id = ``
+	s.createdAt = time.Time{}
+	s.modified = true
+}
+
+// Destroy remove all session values and destroy session from store
+func (s *Session) Destroy() {
+	s.Regenerate()
+	s.val = make(map[string]interface{})
+}
+
 func (s *Session) Remove(key string) {
 	s.init()
 	delete(s.val, key)
+	delete(s.req, key)
+	s.modified = true
 }
 
 func (s *Session) Put(key string, value interface{}) {
 	s.init()
 	s.val[key] = value
+	delete(s.req, key)
+	s.modified = true
+}
+
+// set put value only available in current request, value never saved to store
+func (s *Session) set(key string, value interface{}) {
+	if s.req == nil {
+		s.req = make(map[string]interface{})
+	}
+	s.req[key] = value
+}
+
+// value return request value if exists, otherwise stored value
+func (s *Session) value(key string) (interface{}, bool) {
+	if val, ok := s.req[key]; ok {
+		return val, true
+	}
+	val, ok := s.val[key]
+	return val, ok
+}
+
+func (s *Session) Has(key string) bool {
+	_, ok := s.value(key)
+	return ok
 }
 
 func (s *Session) Get(key string) interface{} {
-	s.init()
-	return s.val[key]
+	val, _ := s.value(key)
+	return val
 }
 
 func (s *Session) GetString(key string) string {
-	s.init()
-	val, ok := s.val[key]
+	val, ok := s.value(key)
 	if !ok || val == nil {
 		return ``
 	}
@@ -45,6 +120,10 @@ func (s *Session) GetString(key string) string {
 		return fmt.Sprintf(`%f`, val)
 	case int:
 		return strconv.Itoa(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case json.Number:
+		return val.String()
 	case string:
 		return val
 	}
@@ -53,16 +132,29 @@ func (s *Session) GetString(key string) string {
 }
 
 func (s *Session) GetInt(key string) int {
-	s.init()
-	val, ok := s.val[key]
+	return int(s.GetInt64(key))
+}
+
+func (s *Session) GetInt64(key string) int64 {
+	val, ok := s.value(key)
 	if !ok || val == nil {
 		return 0
 	}
 	switch val := val.(type) {
 	case int:
+		return int64(val)
+	case int64:
 		return val
+	case float64:
+		return int64(val)
+	case json.Number:
+		i, e := val.Int64()
+		if e != nil {
+			return 0
+		}
+		return i
 	case string:
-		i, e := strconv.Atoi(val)
+		i, e := strconv.ParseInt(val, 10, 64)
 		if e != nil {
 			return 0
 		}
@@ -72,3 +164,75 @@ func (s *Session) GetInt(key string) int {
 	s.logger.W(fmt.Sprintf(`unable convert to string int:%s val: %v type: %s`, key, val, reflect.TypeOf(val).String()))
 	return 0
 }
+
+func (s *Session) GetFloat(key string) float64 {
+	val, ok := s.value(key)
+	if !ok || val == nil {
+		return 0
+	}
+	switch val := val.(type) {
+	case float32:
+		return float64(val)
+	case float64:
+		return val
+	case int:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case json.Number:
+		f, e := val.Float64()
+		if e != nil {
+			return 0
+		}
+		return f
+	case string:
+		f, e := strconv.ParseFloat(val, 64)
+		if e != nil {
+			return 0
+		}
+		return f
+	}
+
+	s.logger.W(fmt.Sprintf(`unable convert to float key:%s val: %v type: %s`, key, val, reflect.TypeOf(val).String()))
+	return 0
+}
+
+func (s *Session) GetBool(key string) bool {
+	val, ok := s.value(key)
+	if !ok || val == nil {
+		return false
+	}
+	switch val := val.(type) {
+	case bool:
+		return val
+	case string:
+		b, e := strconv.ParseBool(val)
+		if e != nil {
+			return false
+		}
+		return b
+	}
+
+	s.logger.W(fmt.Sprintf(`unable convert to bool key:%s val: %v type: %s`, key, val, reflect.TypeOf(val).String()))
+	return false
+}
+
+func (s *Session) GetTime(key string) time.Time {
+	val, ok := s.value(key)
+	if !ok || val == nil {
+		return time.Time{}
+	}
+	switch val := val.(type) {
+	case time.Time:
+		return val
+	case string:
+		t, e := time.Parse(time.RFC3339Nano, val)
+		if e != nil {
+			return time.Time{}
+		}
+		return t
+	}
+
+	s.logger.W(fmt.Sprintf(`unable convert to time key:%s val: %v type: %s`, key, val, reflect.TypeOf(val).String()))
+	return time.Time{}
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eqto/dbm"
+)
+
+// SessionStore persist session values between requests
+type SessionStore interface {
+	// Load return nil values if session not found or already expired
+	Load(id string) (values map[string]interface{}, createdAt time.Time, err error)
+	// Save store session values, session expired after ttl without any activity
+	Save(id string, values map[string]interface{}, createdAt time.Time, ttl time.Duration) error
+	Destroy(id string) error
+	// Touch extend session expiration without changing its values
+	Touch(id string, ttl time.Duration) error
+}
+
+type sessionManager struct {
+	store    SessionStore
+	secret   []byte
+	cookie   string
+	idle     time.Duration
+	absolute time.Duration
+}
+
+func (m *sessionManager) sign(id string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(id))
+	return id + `.` + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify return session id if signature valid
+func (m *sessionManager) verify(value string) (string, bool) {
+	idx := strings.LastIndex(value, `.`)
+	if idx < 0 {
+		return ``, false
+	}
+	id := value[:idx]
+	if !hmac.Equal([]byte(m.sign(id)), []byte(value)) {
+		return ``, false
+	}
+	return id, true
+}
+
+func (m *sessionManager) load(ctx *Context) {
+	sess := ctx.sess
+	sess.loaded = true
+	cookie := ctx.req.Header().Cookie(m.cookie)
+	if cookie == `` {
+		return
+	}
+	id, ok := m.verify(cookie)
+	if !ok {
+		return
+	}
+	values, createdAt, e := m.store.Load(id)
+	if e != nil {
+		ctx.s.logger.W(e)
+		return
+	}
+	if values == nil {
+		return
+	}
+	if m.absolute > 0 && time.Since(createdAt) > m.absolute {
+		if e := m.store.Destroy(id); e != nil {
+			ctx.s.logger.W(e)
+		}
+		return
+	}
+	sess.id = id
+	sess.createdAt = createdAt
+	sess.val = values
+}
+
+func (m *sessionManager) save(ctx *Context) {
+	sess := ctx.sess
+	if !sess.loaded {
+		return
+	}
+	if sess.oldID != `` {
+		if e := m.store.Destroy(sess.oldID); e != nil {
+			ctx.s.logger.W(e)
+		}
+	}
+	header := ctx.resp.Header()
+	if sess.id == `` {
+		if !sess.modified || len(sess.val) == 0 {
+			if sess.oldID != `` {
+				header.DelCookie(m.cookie)
+			}
+			return
+		}
+		id, e := newSessionID()
+		if e != nil {
+			ctx.s.logger.W(e)
+			return
+		}
+		sess.id = id
+		sess.createdAt = time.Now()
+		sess.modified = true
+	}
+	var e error
+	if sess.modified {
+		e = m.store.Save(sess.id, sess.val, sess.createdAt, m.idle)
+	} else {
+		e = m.store.Touch(sess.id, m.idle)
+	}
+	if e != nil {
+		ctx.s.logger.W(e)
+		return
+	}
+	header.SetCookie(m.cookie, m.sign(sess.id), m.idle)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, e := rand.Read(b); e != nil {
+		return ``, e
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type memorySession struct {
+	values    map[string]interface{}
+	createdAt time.Time
+	expiredAt time.Time
+}
+
+type memorySessionStore struct {
+	lock      sync.Mutex
+	items     map[string]*memorySession
+	lastSweep time.Time
+}
+
+func (m *memorySessionStore) Load(id string) (map[string]interface{}, time.Time, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	item, ok := m.items[id]
+	if !ok {
+		return nil, time.Time{}, nil
+	}
+	if time.Now().After(item.expiredAt) {
+		delete(m.items, id)
+		return nil, time.Time{}, nil
+	}
+	return copySessionValues(item.values), item.createdAt, nil
+}
+
+func (m *memorySessionStore) Save(id string, values map[string]interface{}, createdAt time.Time, ttl time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := time.Now()
+	if now.Sub(m.lastSweep) > time.Minute {
+		for key, item := range m.items {
+			if now.After(item.expiredAt) {
+				delete(m.items, key)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.items[id] = &memorySession{
+		values:    copySessionValues(values),
+		createdAt: createdAt,
+		expiredAt: now.Add(ttl),
+	}
+	return nil
+}
+
+func (m *memorySessionStore) Destroy(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.items, id)
+	return nil
+}
+
+func (m *memorySessionStore) Touch(id string, ttl time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if item, ok := m.items[id]; ok {
+		item.expiredAt = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+// NewMemorySessionStore store sessions in memory, expired sessions are evicted periodically. Sessions are lost when the server restarts.
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{items: make(map[string]*memorySession)}
+}
+
+type databaseSessionStore struct {
+	cn    *dbm.Connection
+	table string
+}
+
+func (d *databaseSessionStore) Load(id string) (map[string]interface{}, time.Time, error) {
+	rs, e := d.cn.Get(fmt.Sprintf(`SELECT data, created_at FROM %s WHERE id = ? AND expired_at > ?`, d.table), id, time.Now().Unix())
+	if e != nil {
+		return nil, time.Time{}, e
+	}
+	if rs == nil {
+		return nil, time.Time{}, nil
+	}
+	values := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader([]byte(rs.String(`data`))))
+	dec.UseNumber()
+	if e := dec.Decode(&values); e != nil {
+		return nil, time.Time{}, e
+	}
+	return values, time.Unix(int64(rs.Int(`created_at`)), 0), nil
+}
+
+func (d *databaseSessionStore) Save(id string, values map[string]interface{}, createdAt time.Time, ttl time.Duration) error {
+	data, e := json.Marshal(values)
+	if e != nil {
+		return e
+	}
+	expiredAt := time.Now().Add(ttl).Unix()
+	res, e := d.cn.Exec(fmt.Sprintf(`UPDATE %s SET data = ?, expired_at = ? WHERE id = ?`, d.table), string(data), expiredAt, id)
+	if e != nil {
+		return e
+	}
+	if rows, e := res.RowsAffected(); e == nil && rows > 0 {
+		return nil
+	}
+	_, e = d.cn.Exec(fmt.Sprintf(`INSERT INTO %s (id, data, created_at, expired_at) VALUES (?, ?, ?, ?)`, d.table), id, string(data), createdAt.Unix(), expiredAt)
+	if dbm.IsErrDuplicate(e) {
+		return nil
+	}
+	return e
+}
+
+func (d *databaseSessionStore) Destroy(id string) error {
+	_, e := d.cn.Exec(fmt.Sprintf(`DELETE FROM %s WHERE id = ? OR expired_at <= ?`, d.table), id, time.Now().Unix())
+	return e
+}
+
+func (d *databaseSessionStore) Touch(id string, ttl time.Duration) error {
+	_, e := d.cn.Exec(fmt.Sprintf(`UPDATE %s SET expired_at = ? WHERE id = ?`, d.table), time.Now().Add(ttl).Unix(), id)
+	return e
+}
+
+// NewDatabaseSessionStore store sessions in database table with structure:
+//
+//	CREATE TABLE sessions (
+//	    id VARCHAR(64) NOT NULL PRIMARY KEY,
+//	    data TEXT NOT NULL,
+//	    created_at BIGINT NOT NULL,
+//	    expired_at BIGINT NOT NULL
+//	)
+//
+// Expired sessions are removed whenever a session destroyed.
+func NewDatabaseSessionStore(cn *dbm.Connection, table string) (SessionStore, error) {
+	if cn == nil {
+		return nil, errors.New(`database not available`)
+	}
+	if table == `` {
+		table = `sessions`
+	}
+	return &databaseSessionStore{cn: cn, table: table}, nil
+}
+
+func copySessionValues(values map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(values))
+	for key, val := range values {
+		cp[key] = val
+	}
+	return cp
+}
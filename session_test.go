@@ -0,0 +1,119 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// serveSessionTest serve routes to login and read user_id of session
+func serveSessionTest(t *testing.T, idle, absolute time.Duration) (*fasthttp.Client, *string) {
+	t.Helper()
+	s := newTestServer()
+	s.SetSessionStore(NewMemorySessionStore(), `secret`)
+	s.SetSessionTimeout(idle, absolute)
+	s.Post(`/login`).AddAction(func(ctx *Context) error {
+		ctx.Session().Put(`user_id`, `alice`)
+		return nil
+	})
+	userID := new(string)
+	s.Get(`/me`).AddAction(func(ctx *Context) error {
+		*userID = ctx.Session().GetString(`user_id`)
+		return nil
+	})
+	return serveTest(t, s), userID
+}
+
+func sessionCookie(t *testing.T, resp *fasthttp.Response) string {
+	t.Helper()
+	cookie := &fasthttp.Cookie{}
+	if e := cookie.ParseBytes(resp.Header.PeekCookie(`session_id`)); e != nil {
+		t.Fatal(`session cookie not set: `, e)
+	}
+	return string(cookie.Value())
+}
+
+func sessionUser(t *testing.T, client *fasthttp.Client, userID *string, cookie string) string {
+	t.Helper()
+	*userID = ``
+	doTest(t, client, MethodGet, `/me`, map[string]string{`Cookie`: `session_id=` + cookie})
+	return *userID
+}
+
+func TestSessionPersistBetweenRequests(t *testing.T) {
+	client, userID := serveSessionTest(t, time.Minute, 0)
+	cookie := sessionCookie(t, doTest(t, client, MethodPost, `/login`, nil))
+
+	if user := sessionUser(t, client, userID, cookie); user != `alice` {
+		t.Errorf(`expected user_id alice, got %q`, user)
+	}
+	if user := sessionUser(t, client, userID, ``); user != `` {
+		t.Errorf(`expected no user_id without cookie, got %q`, user)
+	}
+}
+
+func TestSessionRejectTamperedCookie(t *testing.T) {
+	client, userID := serveSessionTest(t, time.Minute, 0)
+	cookie := sessionCookie(t, doTest(t, client, MethodPost, `/login`, nil))
+
+	m := &sessionManager{secret: []byte(`secret`)}
+	other := &sessionManager{secret: []byte(`other`)}
+	id, ok := m.verify(cookie)
+	if !ok {
+		t.Fatalf(`cookie %s not signed with secret`, cookie)
+	}
+	for _, tampered := range []string{id, id + `.invalid`, other.sign(id), cookie[1:]} {
+		if user := sessionUser(t, client, userID, tampered); user != `` {
+			t.Errorf(`cookie %s accepted, got user_id %q`, tampered, user)
+		}
+	}
+}
+
+func TestSessionIdleExpiry(t *testing.T) {
+	client, userID := serveSessionTest(t, 200*time.Millisecond, 0)
+	cookie := sessionCookie(t, doTest(t, client, MethodPost, `/login`, nil))
+
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if user := sessionUser(t, client, userID, cookie); user != `alice` {
+			t.Fatalf(`session expired while active, got user_id %q`, user)
+		}
+	}
+	time.Sleep(300 * time.Millisecond)
+	if user := sessionUser(t, client, userID, cookie); user != `` {
+		t.Errorf(`expected session expired after idle timeout, got user_id %q`, user)
+	}
+}
+
+func TestSessionAbsoluteExpiry(t *testing.T) {
+	client, userID := serveSessionTest(t, time.Minute, 300*time.Millisecond)
+	cookie := sessionCookie(t, doTest(t, client, MethodPost, `/login`, nil))
+
+	if user := sessionUser(t, client, userID, cookie); user != `alice` {
+		t.Fatalf(`expected user_id alice, got %q`, user)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if user := sessionUser(t, client, userID, cookie); user != `` {
+		t.Errorf(`expected session expired after absolute timeout, got user_id %q`, user)
+	}
+}
+
+func TestMemorySessionStoreExpiry(t *testing.T) {
+	store := NewMemorySessionStore()
+	if e := store.Save(`a`, map[string]interface{}{`user_id`: `alice`}, time.Now(), 50*time.Millisecond); e != nil {
+		t.Fatal(e)
+	}
+	values, _, e := store.Load(`a`)
+	if e != nil || values[`user_id`] != `alice` {
+		t.Fatalf(`expected saved values, got %v %v`, values, e)
+	}
+	values[`user_id`] = `bob`
+	if values, _, _ := store.Load(`a`); values[`user_id`] != `alice` {
+		t.Error(`loaded values share map with store`)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if values, _, _ := store.Load(`a`); values != nil {
+		t.Errorf(`expected expired session, got %v`, values)
+	}
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/eqto/dbm"
+	gojson "github.com/eqto/go-json"
+)
+
+const (
+	castBool    = `bool`
+	castInt     = `int`
+	castFloat   = `float`
+	castString  = `string`
+	castDecimal = `decimal`
+	castJSON    = `json`
+)
+
+// resultShape declarative shaping of select result rows
+type resultShape struct {
+	casts   map[string]string
+	renames map[string]string
+	omits   map[string]struct{}
+	nests   []resultNest
+	groupBy string
+	attach  *resultAttach
+}
+
+type resultNest struct {
+	prefix string
+	array  bool
+}
+
+type resultAttach struct {
+	parent    string
+	field     string
+	parentKey string
+	childKey  string
+}
+
+func (s *resultShape) empty() bool {
+	return s.casts == nil && s.renames == nil && s.omits == nil && s.nests == nil && s.groupBy == ``
+}
+
+// apply shape rows of select and get result, other values returned as is
+func (s *resultShape) apply(data interface{}) (interface{}, error) {
+	if s.empty() {
+		return data, nil
+	}
+	switch data := data.(type) {
+	case []dbm.Resultset:
+		rows := make([]gojson.Object, 0, len(data))
+		for _, rs := range data {
+			row, e := s.row(rs)
+			if e != nil {
+				return nil, e
+			}
+			rows = append(rows, row)
+		}
+		if s.groupBy != `` {
+			rows = s.group(rows)
+		}
+		return rows, nil
+	case dbm.Resultset:
+		return s.row(data)
+	}
+	return data, nil
+}
+
+func (s *resultShape) row(rs dbm.Resultset) (gojson.Object, error) {
+	row := gojson.Object{}
+	for col, val := range rs {
+		if _, ok := s.omits[col]; ok {
+			continue
+		}
+		val = derefValue(val)
+		if typ, ok := s.casts[col]; ok {
+			v, e := castValue(typ, val)
+			if e != nil {
+				return nil, fmt.Errorf(`unable to cast %s to %s: %s`, col, typ, e)
+			}
+			val = v
+		} else if b, ok := val.([]byte); ok {
+			val = string(b)
+		}
+		if name, ok := s.renames[col]; ok {
+			col = name
+		}
+		row[col] = val
+	}
+	for _, nest := range s.nests {
+		child := gojson.Object{}
+		notNull := false
+		for col, val := range row {
+			if strings.HasPrefix(col, nest.prefix+`.`) {
+				child[col[len(nest.prefix)+1:]] = val
+				if val != nil {
+					notNull = true
+				}
+				delete(row, col)
+			}
+		}
+		switch {
+		case nest.array && notNull:
+			row[nest.prefix] = []interface{}{child}
+		case nest.array:
+			row[nest.prefix] = []interface{}{}
+		case notNull:
+			row[nest.prefix] = child
+		default: //left join without match
+			row[nest.prefix] = nil
+		}
+	}
+	return row, nil
+}
+
+// group merge rows having the same groupBy value, arrays of nested prefixes concatenated without duplicate items
+func (s *resultShape) group(rows []gojson.Object) []gojson.Object {
+	grouped := []gojson.Object{}
+	index := map[string]gojson.Object{}
+	// seen items of nested arrays keyed by groupBy value and prefix
+	seen := map[string]map[string]struct{}{}
+	for _, row := range rows {
+		key := fmt.Sprint(row[s.groupBy])
+		first, ok := index[key]
+		if !ok {
+			first = row
+			index[key] = row
+			grouped = append(grouped, row)
+		}
+		for _, nest := range s.nests {
+			if !nest.array {
+				continue
+			}
+			seenKey := key + "\x00" + nest.prefix
+			if seen[seenKey] == nil {
+				seen[seenKey] = map[string]struct{}{}
+			}
+			items := []interface{}{}
+			if ok {
+				items, _ = first[nest.prefix].([]interface{})
+			}
+			for _, item := range row[nest.prefix].([]interface{}) {
+				itemKey := fmt.Sprint(item)
+				if _, dup := seen[seenKey][itemKey]; !dup {
+					seen[seenKey][itemKey] = struct{}{}
+					items = append(items, item)
+				}
+			}
+			first[nest.prefix] = items
+		}
+	}
+	return grouped
+}
+
+// apply attach rows as child field of parent rows, parent is $var or response property
+func (a *resultAttach) apply(ctx *Context, data interface{}) error {
+	var parent interface{}
+	if strings.HasPrefix(a.parent, `$`) {
+		parent = ctx.lookup(a.parent)
+	} else {
+		parent = ctx.resp.data[a.parent]
+	}
+	children := reflect.ValueOf(data)
+	if children.Kind() == reflect.Map {
+		children = reflect.ValueOf([]interface{}{data})
+	}
+	if children.Kind() != reflect.Slice {
+		return fmt.Errorf(`unable to attach %s: result is not rows`, a.field)
+	}
+	byKey := map[string][]interface{}{}
+	for i := 0; i < children.Len(); i++ {
+		child := children.Index(i).Interface()
+		key := fmt.Sprint(fieldOf(child, a.childKey))
+		byKey[key] = append(byKey[key], child)
+	}
+	attach := func(row interface{}) {
+		val := reflect.ValueOf(row)
+		if val.Kind() != reflect.Map {
+			return
+		}
+		items := byKey[fmt.Sprint(fieldOf(row, a.parentKey))]
+		if items == nil {
+			items = []interface{}{}
+		}
+		val.SetMapIndex(reflect.ValueOf(a.field).Convert(val.Type().Key()), reflect.ValueOf(items))
+	}
+	rows := reflect.ValueOf(parent)
+	switch rows.Kind() {
+	case reflect.Slice:
+		for i := 0; i < rows.Len(); i++ {
+			attach(rows.Index(i).Interface())
+		}
+	case reflect.Map:
+		attach(parent)
+	default:
+		return fmt.Errorf(`unable to attach %s: parent %s not found`, a.field, a.parent)
+	}
+	return nil
+}
+
+func castValue(typ string, val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+	if b, ok := val.([]byte); ok {
+		val = string(b)
+	}
+	switch typ {
+	case castBool:
+		if f, ok := toFloat(val); ok {
+			return f != 0, nil
+		}
+		if b, ok := toBool(val); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf(`invalid boolean %v`, val)
+	case castInt:
+		switch v := val.(type) {
+		case int64:
+			return v, nil
+		case float32:
+			return int64(v), nil
+		case float64:
+			return int64(v), nil
+		}
+		// parse as integer first, bigint above 2^53 lose precision as float
+		str := strings.TrimSpace(fmt.Sprint(val))
+		if i, e := strconv.ParseInt(str, 10, 64); e == nil {
+			return i, nil
+		}
+		f, e := strconv.ParseFloat(str, 64)
+		if e != nil {
+			return nil, fmt.Errorf(`invalid integer %v`, val)
+		}
+		return int64(f), nil
+	case castFloat:
+		switch v := val.(type) {
+		case float64:
+			return v, nil
+		case float32:
+			return float64(v), nil
+		}
+		f, e := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(val)), 64)
+		if e != nil {
+			return nil, fmt.Errorf(`invalid number %v`, val)
+		}
+		return f, nil
+	case castString:
+		return fmt.Sprint(val), nil
+	case castDecimal:
+		switch v := val.(type) {
+		case float32:
+			return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+		return fmt.Sprint(val), nil
+	case castJSON:
+		str, ok := val.(string)
+		if !ok {
+			return val, nil
+		}
+		var v interface{}
+		if e := json.Unmarshal([]byte(str), &v); e != nil {
+			return nil, e
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf(`unsupported type %s`, typ)
+}
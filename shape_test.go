@@ -0,0 +1,97 @@
+package api
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eqto/dbm"
+)
+
+func TestShapeNestAndGroup(t *testing.T) {
+	q := &actionQuery{}
+	q.Cast(`active`, castBool).Cast(`price`, castDecimal).Cast(`meta`, castJSON).Rename(`title`, `name`).Omit(`secret`)
+	q.Nest(`author`).NestArray(`tags`).GroupBy(`id`)
+	shape := q.shape
+	rows := []dbm.Resultset{
+		{`id`: int64(1), `title`: `Go`, `active`: int64(1), `price`: 12.5, `meta`: []byte(`{"pages":300}`), `secret`: `x`,
+			`author.name`: `Ann`, `tags.name`: `dev`},
+		{`id`: int64(1), `title`: `Go`, `active`: int64(1), `price`: 12.5, `meta`: []byte(`{"pages":300}`), `secret`: `x`,
+			`author.name`: `Ann`, `tags.name`: `lang`},
+		{`id`: int64(2), `title`: `SQL`, `active`: `false`, `price`: 7.0, `meta`: nil, `secret`: `y`,
+			`author.name`: nil, `tags.name`: nil},
+	}
+	data, e := shape.apply(rows)
+	if e != nil {
+		t.Fatal(e)
+	}
+	js, e := json.Marshal(data)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expected := `[{"active":true,"author":{"name":"Ann"},"id":1,"meta":{"pages":300},"name":"Go","price":"12.5","tags":[{"name":"dev"},{"name":"lang"}]},` +
+		`{"active":false,"author":null,"id":2,"meta":null,"name":"SQL","price":"7","tags":[]}]`
+	if string(js) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, js)
+	}
+}
+
+func TestShapeCastInvalidValue(t *testing.T) {
+	shape := resultShape{casts: map[string]string{`id`: castInt}}
+	if _, e := shape.apply(dbm.Resultset{`id`: `abc`}); e == nil {
+		t.Error(`expected error casting abc to int`)
+	}
+}
+
+func TestCastUnsupportedType(t *testing.T) {
+	s := newTestServer()
+	cn, _ := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	route := s.Get(`/books`)
+	route.AddQueryAction(`SELECT * FROM books`, ``).Cast(`price`, `money`)
+	ctx := newTestContext(t, s, MethodGet, `/books`, nil)
+	if e := route.executeActions(ctx); e == nil || e.Error() != `unsupported cast type money for price` {
+		t.Errorf(`expected unsupported cast type error, got %v`, e)
+	}
+	if e := s.Serve(0); e == nil || !strings.Contains(e.Error(), `route GET /books: unsupported cast type`) {
+		t.Errorf(`expected Serve to fail with unsupported cast type, got %v`, e)
+	}
+}
+
+func TestAttachTo(t *testing.T) {
+	s := newTestServer()
+	cn, db := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	db.handler = func(query string, args []interface{}) (*testResult, error) {
+		switch {
+		case strings.HasPrefix(query, `SELECT id FROM orders`):
+			return &testResult{cols: []string{`id`}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}}, nil
+		case strings.HasPrefix(query, `SELECT order_id, name FROM items`):
+			return &testResult{cols: []string{`order_id`, `name`}, rows: [][]driver.Value{{int64(1), `a`}, {int64(1), `b`}}}, nil
+		}
+		return nil, nil
+	}
+	route := s.Get(`/orders`)
+	route.AddQueryAction(`SELECT id FROM orders`, ``)
+	route.AddQueryAction(`SELECT order_id, name FROM items`, ``).AttachTo(`data`, `items`, `id`, `order_id`)
+
+	resp := doTest(t, serveTest(t, s), MethodGet, `/orders`, nil)
+	body := struct {
+		Data []map[string]interface{} `json:"data"`
+	}{}
+	if e := json.Unmarshal(resp.Body(), &body); e != nil {
+		t.Fatalf(`%s: %s`, e, resp.Body())
+	}
+	expected := []map[string]interface{}{
+		{`id`: float64(1), `items`: []interface{}{
+			map[string]interface{}{`order_id`: float64(1), `name`: `a`},
+			map[string]interface{}{`order_id`: float64(1), `name`: `b`},
+		}},
+		{`id`: float64(2), `items`: []interface{}{}},
+	}
+	if !reflect.DeepEqual(body.Data, expected) {
+		t.Errorf(`expected %v, got %v`, expected, body.Data)
+	}
+}
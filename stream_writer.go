@@ -1,28 +1,74 @@
 package api
 
-import "bufio"
+import (
+	"bufio"
+	"errors"
+	"sync"
+)
+
+var errStreamClosed = errors.New(`stream already closed`)
 
 type streamWriter struct {
 	Writer
-	doneCh chan bool
-	writer *bufio.Writer
+	lock    sync.Mutex
+	startCh chan struct{}
+	closeCh chan struct{}
+	writer  *bufio.Writer
+	closed  bool
+}
+
+func newStreamWriter() *streamWriter {
+	return &streamWriter{startCh: make(chan struct{}), closeCh: make(chan struct{})}
 }
 
 func (s *streamWriter) write(w *bufio.Writer) {
-	s.doneCh = make(chan bool)
+	s.lock.Lock()
 	s.writer = w
-	<-s.doneCh
+	s.lock.Unlock()
+	close(s.startCh)
+	<-s.closeCh
+}
+
+// wait until response started streaming, return false if closed before started
+func (s *streamWriter) wait() bool {
+	select {
+	case <-s.startCh:
+		return true
+	case <-s.closeCh:
+		return false
+	}
 }
 
 func (s *streamWriter) Write(data []byte) (int, error) {
+	if !s.wait() {
+		return 0, errStreamClosed
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return 0, errStreamClosed
+	}
 	return s.writer.Write(data)
 }
 
 func (s *streamWriter) Flush() error {
+	if !s.wait() {
+		return errStreamClosed
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return errStreamClosed
+	}
 	return s.writer.Flush()
 }
 
 func (s *streamWriter) Close() error {
-	s.doneCh <- true
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.closeCh)
+	}
 	return nil
 }
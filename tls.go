@@ -0,0 +1,220 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// certCheckInterval minimum interval between checking certificate files for changes
+const certCheckInterval = 10 * time.Second
+
+type certificate struct {
+	certFile string
+	keyFile  string
+	modTime  time.Time
+	cert     *tls.Certificate
+}
+
+// certStore certificates selected by SNI, reloaded when files changed
+type certStore struct {
+	lock      sync.Mutex
+	certs     []*certificate
+	checkedAt time.Time
+	logger    *logger
+}
+
+// AddCertificate add certificate for TLS, selected using SNI of client. First certificate is the default one for client without SNI or with unknown server name. Certificate reloaded automatically when files changed.
+func (s *Server) AddCertificate(certFile, keyFile string) error {
+	cert, e := loadCertificate(certFile, keyFile)
+	if e != nil {
+		return e
+	}
+	if s.certs == nil {
+		s.certs = &certStore{logger: s.logger}
+	}
+	s.certs.lock.Lock()
+	defer s.certs.lock.Unlock()
+	for i, c := range s.certs.certs {
+		if c.certFile == certFile && c.keyFile == keyFile {
+			s.certs.certs[i] = cert
+			return nil
+		}
+	}
+	s.certs.certs = append(s.certs.certs, cert)
+	return nil
+}
+
+// SetClientCA verify client certificates using CA certificates in PEM file caFile. If required is false client without certificate allowed. Verified certificate available using Context.ClientCertificate.
+func (s *Server) SetClientCA(caFile string, required bool) error {
+	pem, e := os.ReadFile(caFile)
+	if e != nil {
+		return e
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf(`no certificate found in %s`, caFile)
+	}
+	s.clientCAs = pool
+	s.clientAuth = tls.VerifyClientCertIfGiven
+	if required {
+		s.clientAuth = tls.RequireAndVerifyClientCert
+	}
+	return nil
+}
+
+// SetNetwork set network of Serve, ServeTLS and ServeRedirect: tcp (default, dual-stack IPv4 and IPv6), tcp4 or tcp6
+func (s *Server) SetNetwork(network string) {
+	s.network = network
+}
+
+// ServeTLS serve HTTPS on port, certFile and keyFile added using AddCertificate. Leave certFile empty to use certificates already added.
+func (s *Server) ServeTLS(port int, certFile, keyFile string) error {
+	if certFile != `` {
+		if e := s.AddCertificate(certFile, keyFile); e != nil {
+			return e
+		}
+	}
+	if s.certs == nil || len(s.certs.certs) == 0 {
+		return errors.New(`no certificate added`)
+	}
+	s.certs.lock.Lock()
+	s.certs.logger = s.logger
+	s.certs.lock.Unlock()
+	ln, e := s.listen(port)
+	if e != nil {
+		return e
+	}
+	defer ln.Close()
+
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{`http/1.1`},
+		GetCertificate: s.certs.get,
+		ClientCAs:      s.clientCAs,
+		ClientAuth:     s.clientAuth,
+	}
+	return s.serve(tls.NewListener(ln, cfg))
+}
+
+// ServeRedirect serve HTTP on port redirecting all requests to HTTPS on tlsPort
+func (s *Server) ServeRedirect(port, tlsPort int) error {
+	ln, e := s.listen(port)
+	if e != nil {
+		return e
+	}
+	defer ln.Close()
+
+	s.redirectServ = &fasthttp.Server{
+		Handler: func(fastCtx *fasthttp.RequestCtx) {
+			host := string(fastCtx.Host())
+			if h, _, e := net.SplitHostPort(host); e == nil {
+				host = h
+			}
+			if tlsPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(tlsPort))
+			}
+			fastCtx.Response.Header.Set(`Location`, `https://`+host+string(fastCtx.RequestURI()))
+			fastCtx.SetStatusCode(StatusPermanentRedirect)
+		},
+		NoDefaultServerHeader: true,
+		CloseOnShutdown:       true,
+	}
+	return s.redirectServ.Serve(ln)
+}
+
+func (s *Server) listen(port int) (net.Listener, error) {
+	network := s.network
+	if network == `` {
+		network = `tcp`
+	}
+	return net.Listen(network, fmt.Sprintf(`:%d`, port))
+}
+
+func loadCertificate(certFile, keyFile string) (*certificate, error) {
+	modTime, e := certModTime(certFile, keyFile)
+	if e != nil {
+		return nil, e
+	}
+	cert, e := tls.LoadX509KeyPair(certFile, keyFile)
+	if e != nil {
+		return nil, e
+	}
+	if cert.Leaf, e = x509.ParseCertificate(cert.Certificate[0]); e != nil {
+		return nil, e
+	}
+	return &certificate{certFile: certFile, keyFile: keyFile, modTime: modTime, cert: &cert}, nil
+}
+
+// certModTime return latest modification time of certificate and key files
+func certModTime(certFile, keyFile string) (time.Time, error) {
+	modTime := time.Time{}
+	for _, file := range []string{certFile, keyFile} {
+		info, e := os.Stat(file)
+		if e != nil {
+			return modTime, e
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+// get return certificate supported by client, reload changed certificates at most once every certCheckInterval
+func (c *certStore) get(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if now := time.Now(); now.After(c.checkedAt.Add(certCheckInterval)) {
+		c.checkedAt = now
+		c.reload()
+	}
+	if len(c.certs) == 0 {
+		return nil, errors.New(`no certificate added`)
+	}
+	for _, cert := range c.certs {
+		if hello.SupportsCertificate(cert.cert) == nil {
+			return cert.cert, nil
+		}
+	}
+	return c.certs[0].cert, nil
+}
+
+// reload load certificates having changed files, previous certificate kept if reload failed
+func (c *certStore) reload() {
+	for i, cert := range c.certs {
+		modTime, e := certModTime(cert.certFile, cert.keyFile)
+		if e != nil || !modTime.After(cert.modTime) {
+			continue
+		}
+		newCert, e := loadCertificate(cert.certFile, cert.keyFile)
+		if e != nil {
+			// files may be partially written, retry on next check
+			c.logger.W(`reload certificate failed, previous certificate kept:`, e)
+			continue
+		}
+		c.certs[i] = newCert
+		c.logger.I(`certificate reloaded:`, cert.certFile)
+	}
+}
+
+// TLS return TLS state of connection, nil if request not using HTTPS
+func (c *Context) TLS() *tls.ConnectionState {
+	return c.fastCtx.TLSConnectionState()
+}
+
+// ClientCertificate return verified client certificate, nil if client sent no certificate or request not using HTTPS
+func (c *Context) ClientCertificate() *x509.Certificate {
+	if state := c.TLS(); state != nil && len(state.PeerCertificates) > 0 {
+		return state.PeerCertificates[0]
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eqto/dbm"
+)
+
+// TxMode transaction policy of route
+type TxMode uint8
+
+const (
+	// TxReadWrite single transaction for all actions of route, committed when all actions succeed (default)
+	TxReadWrite TxMode = iota
+	// TxReadOnly transaction that rejects insert, update and delete query actions, always rolled back. Transaction access mode not set on database, so Context.Tx return error instead of transaction that could be written silently; use Context.Database to read in function actions.
+	TxReadOnly
+	// TxNone query actions executed directly on connection, each statement committed on its own. Context.Tx return error, use Context.Database instead.
+	TxNone
+)
+
+const (
+	IsolationReadUncommitted = `READ UNCOMMITTED`
+	IsolationReadCommitted   = `READ COMMITTED`
+	IsolationRepeatableRead  = `REPEATABLE READ`
+	IsolationSerializable    = `SERIALIZABLE`
+)
+
+var (
+	errReadOnlyTx  = errors.New(`write query not allowed in read-only transaction`)
+	errTxDisabled  = errors.New(`transaction disabled for route, use Database instead`)
+	errTxParallel  = errors.New(`transaction not available in parallel action`)
+	errParallelTx  = errors.New(`write query not allowed in parallel action`)
+	errTxReadOnly  = errors.New(`transaction not available in read-only route, use Database instead`)
+	errIsolationDB = errors.New(`isolation level only supported on sqlserver`)
+)
+
+// queryRunner implemented by both *dbm.Connection and *dbm.Tx
+type queryRunner interface {
+	Select(query string, args ...interface{}) ([]dbm.Resultset, error)
+	Get(query string, args ...interface{}) (dbm.Resultset, error)
+	Exec(query string, args ...interface{}) (*dbm.Result, error)
+}
+
+type txPolicy struct {
+	mode      TxMode
+	isolation string
+	retry     int
+}
+
+// Commit commit current transactions before route finished, next call to Tx begin a new transaction
+func (c *Context) Commit() error {
+	if c.tx.mode == TxReadOnly {
+		return c.Rollback()
+	}
+	var err error
+	for name, tx := range c.txs {
+		if e := tx.Commit(); e != nil && err == nil {
+			err = e
+		}
+		delete(c.txs, name)
+	}
+	return err
+}
+
+// Rollback rollback current transactions, next call to Tx begin a new transaction
+func (c *Context) Rollback() error {
+	var err error
+	for name, tx := range c.txs {
+		if e := tx.Rollback(); e != nil && err == nil {
+			err = e
+		}
+		delete(c.txs, name)
+	}
+	return err
+}
+
+// Savepoint create savepoint in current transaction, use RollbackTo to undo changes made after it without aborting whole transaction
+func (c *Context) Savepoint(name string) error {
+	return c.savepointExec(name, `SAVEPOINT %s`, `SAVE TRANSACTION %s`)
+}
+
+// RollbackTo rollback current transaction to savepoint
+func (c *Context) RollbackTo(name string) error {
+	return c.savepointExec(name, `ROLLBACK TO SAVEPOINT %s`, `ROLLBACK TRANSACTION %s`)
+}
+
+// ReleaseSavepoint release savepoint, no-op for sqlserver
+func (c *Context) ReleaseSavepoint(name string) error {
+	return c.savepointExec(name, `RELEASE SAVEPOINT %s`, ``)
+}
+
+// IndependentTx execute fn in a new transaction independent of request transaction, ex: for audit writes that must persist even if request fails. Transaction committed if fn return nil.
+func (c *Context) IndependentTx(fn func(tx *dbm.Tx) error) error {
+	cn, e := c.Database()
+	if e != nil {
+		return e
+	}
+	tx, e := cn.Begin()
+	if e != nil {
+		return e
+	}
+	if e := fn(tx); e != nil {
+		tx.Rollback()
+		return e
+	}
+	return tx.Commit()
+}
+
+func (c *Context) savepointExec(name, mysqlFormat, sqlserverFormat string) error {
+	if !identifierRegex.MatchString(name) {
+		return fmt.Errorf(`invalid savepoint name: %s`, name)
+	}
+	cn, e := c.Database()
+	if e != nil {
+		return e
+	}
+	tx, e := c.Tx()
+	if e != nil {
+		return e
+	}
+	format := mysqlFormat
+	if cn.Driver().Name() == `sqlserver` {
+		format = sqlserverFormat
+	}
+	if format == `` {
+		return nil
+	}
+	_, e = tx.Exec(fmt.Sprintf(format, name))
+	return e
+}
+
+// beginTx begin transaction with route isolation level, isolation of route checked by checkIsolation before serving
+func (c *Context) beginTx(cn *dbm.Connection) (*dbm.Tx, error) {
+	if c.tx.isolation != `` && cn.Driver().Name() != `sqlserver` {
+		return nil, errIsolationDB
+	}
+	tx, e := cn.Begin()
+	if e != nil {
+		return nil, e
+	}
+	if c.tx.isolation != `` {
+		if _, e := tx.Exec(`SET TRANSACTION ISOLATION LEVEL ` + c.tx.isolation); e != nil {
+			tx.Rollback()
+			return nil, e
+		}
+	}
+	return tx, nil
+}
+
+// checkIsolation return error if route with isolation level use database other than sqlserver. dbm begin transaction without options, so isolation of mysql transaction can not be set before it started.
+func (s *Server) checkIsolation(t *routeTable) error {
+	for _, method := range methods {
+		var err error
+		t.routers[method].walk(func(path string, route *Route) {
+			if err != nil || route.tx.isolation == `` || route.tx.mode == TxNone {
+				return
+			}
+			names := []string{route.dbName}
+			for _, act := range route.action {
+				if q, ok := act.(*actionQuery); ok && q.dbName != `` {
+					names = append(names, q.dbName)
+				}
+			}
+			for _, name := range names {
+				if db := s.database(name); db != nil && db.cn.Driver() != nil && db.cn.Driver().Name() != `sqlserver` {
+					err = fmt.Errorf(`route %s %s: %s`, method, path, errIsolationDB)
+					return
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetForRetry rollback transaction and discard response of failed attempt
+func (c *Context) resetForRetry() {
+	c.Rollback()
+	c.retryable = false
+	c.invalidateTags = nil
+	c.vars = nil
+	c.resp.data = nil
+	c.resp.pages = nil
+	c.resp.err = nil
+	c.resp.stop = false
+	c.resp.statusCode = 0
+	c.resp.statusMsg = nil
+	c.resp.httpResp.SetStatusCode(StatusOK)
+}
+
+// isRetryableErr deadlock or serialization failure, ex: mysql error 1213 and sqlserver error 1205
+func isRetryableErr(e error) bool {
+	if e == nil {
+		return false
+	}
+	msg := strings.ToLower(e.Error())
+	return strings.Contains(msg, `deadlock`) || strings.Contains(msg, `40001`) || strings.Contains(msg, `could not serialize`)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eqto/dbm"
+	"github.com/valyala/fasthttp"
+)
+
+func txTestRoute(t *testing.T, mode TxMode, query string) (*Route, *Context) {
+	t.Helper()
+	s := newTestServer()
+	s.AddDatabase(``, &dbm.Connection{})
+	route := s.Post(`/logs`).Transaction(mode)
+	route.AddQueryAction(query, ``)
+	ctx := newTestContext(t, s, MethodPost, `/logs`, nil)
+	ctx.tx = route.tx
+	return route, ctx
+}
+
+func TestReadOnlyTxRejectWrite(t *testing.T) {
+	queries := []string{
+		`INSERT INTO logs (msg) VALUES ('a')`,
+		`UPDATE logs SET msg = 'b'`,
+		`DELETE FROM logs`,
+	}
+	for _, query := range queries {
+		route, ctx := txTestRoute(t, TxReadOnly, query)
+		if e := route.executeActions(ctx); e != errReadOnlyTx {
+			t.Errorf(`%s: expected read-only error, got %v`, query, e)
+		}
+		if len(ctx.txs) > 0 {
+			t.Errorf(`%s: transaction started for rejected write`, query)
+		}
+	}
+}
+
+func TestParallelRejectWrite(t *testing.T) {
+	route, ctx := txTestRoute(t, TxReadWrite, `DELETE FROM logs`)
+	route.action[0].Parallel()
+	if e := route.executeActions(ctx); e != errParallelTx {
+		t.Errorf(`expected parallel write error, got %v`, e)
+	}
+}
+
+func TestParallelInheritReadOnlyTx(t *testing.T) {
+	_, ctx := txTestRoute(t, TxReadOnly, `DELETE FROM logs`)
+	if child := ctx.fork(); child.tx.mode != TxReadOnly {
+		t.Errorf(`expected forked context to keep read-only mode, got %d`, child.tx.mode)
+	}
+}
+
+func TestTxNoneWithoutTransaction(t *testing.T) {
+	_, ctx := txTestRoute(t, TxNone, `DELETE FROM logs`)
+	if tx, e := ctx.Tx(); e != errTxDisabled || tx != nil {
+		t.Errorf(`expected transaction disabled error, got %v`, e)
+	}
+}
+
+func TestReadOnlyTxNotAvailableToFunction(t *testing.T) {
+	_, ctx := txTestRoute(t, TxReadOnly, `SELECT * FROM logs`)
+	if tx, e := ctx.Tx(); e != errTxReadOnly || tx != nil {
+		t.Errorf(`expected read-only transaction error, got %v`, e)
+	}
+}
+
+func TestReadOnlyQueryInRolledBackTx(t *testing.T) {
+	s := newTestServer()
+	cn, db := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	s.Get(`/logs`).Transaction(TxReadOnly).AddQueryAction(`SELECT * FROM logs`, ``)
+
+	if resp := doTest(t, serveTest(t, s), MethodGet, `/logs`, nil); resp.StatusCode() != StatusOK {
+		t.Fatalf(`expected status 200, got %d: %s`, resp.StatusCode(), resp.Body())
+	}
+	expected := []string{`BEGIN`, `SELECT * FROM logs LIMIT 0, 1000`, `ROLLBACK`}
+	if queries := db.executed(); !reflect.DeepEqual(queries, expected) {
+		t.Errorf(`expected %v, got %v`, expected, queries)
+	}
+}
+
+func TestIsolationRejectedOnMySQL(t *testing.T) {
+	s := newTestServer()
+	cn, _ := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	s.Post(`/logs`).Isolation(IsolationSerializable).AddQueryAction(`DELETE FROM logs`, ``)
+	if e := s.checkIsolation(s.routes()); e == nil {
+		t.Error(`expected error for isolation on mysql`)
+	}
+	if e := s.Serve(0); e == nil || !strings.Contains(e.Error(), `isolation`) {
+		t.Errorf(`expected Serve to fail with isolation error, got %v`, e)
+	}
+}
+
+func TestIsolationOnSQLServer(t *testing.T) {
+	s := newTestServer()
+	cn, db := newTestDB(t, `sqlserver`)
+	s.SetDatabase(cn)
+	s.Post(`/logs`).Isolation(IsolationSerializable).AddQueryAction(`DELETE FROM logs`, ``)
+	if e := s.checkIsolation(s.routes()); e != nil {
+		t.Fatal(e)
+	}
+	if resp := doTest(t, serveTest(t, s), MethodPost, `/logs`, nil); resp.StatusCode() != StatusOK {
+		t.Fatalf(`expected status 200, got %d: %s`, resp.StatusCode(), resp.Body())
+	}
+	expected := []string{`BEGIN`, `SET TRANSACTION ISOLATION LEVEL SERIALIZABLE`, `DELETE FROM logs`, `COMMIT`}
+	if queries := db.executed(); !reflect.DeepEqual(queries, expected) {
+		t.Errorf(`expected %v, got %v`, expected, queries)
+	}
+}
+
+// deadlockDB fail first failures delete statements with deadlock error
+func deadlockDB(t *testing.T, mode TxMode, failures int) (*fasthttp.Client, *testDB) {
+	t.Helper()
+	s := newTestServer()
+	cn, db := newTestDB(t, `mysql`)
+	s.SetDatabase(cn)
+	db.handler = func(query string, args []interface{}) (*testResult, error) {
+		if strings.HasPrefix(query, `DELETE`) && failures > 0 {
+			failures--
+			return nil, errors.New(`Error 1213: Deadlock found when trying to get lock`)
+		}
+		return nil, nil
+	}
+	s.Post(`/logs`).Transaction(mode).RetryOnDeadlock(3).AddQueryAction(`DELETE FROM logs`, ``)
+	return serveTest(t, s), db
+}
+
+func TestRetryOnDeadlock(t *testing.T) {
+	client, db := deadlockDB(t, TxReadWrite, 1)
+	if resp := doTest(t, client, MethodPost, `/logs`, nil); resp.StatusCode() != StatusOK {
+		t.Fatalf(`expected status 200, got %d: %s`, resp.StatusCode(), resp.Body())
+	}
+	expected := []string{`BEGIN`, `DELETE FROM logs`, `ROLLBACK`, `BEGIN`, `DELETE FROM logs`, `COMMIT`}
+	if queries := db.executed(); !reflect.DeepEqual(queries, expected) {
+		t.Errorf(`expected %v, got %v`, expected, queries)
+	}
+}
+
+func TestTxNoneNotRetried(t *testing.T) {
+	client, db := deadlockDB(t, TxNone, 1)
+	doTest(t, client, MethodPost, `/logs`, nil)
+	if queries := db.executed(); !reflect.DeepEqual(queries, []string{`DELETE FROM logs`}) {
+		t.Errorf(`expected single delete without transaction, got %v`, queries)
+	}
+}
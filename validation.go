@@ -0,0 +1,367 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gojson "github.com/eqto/go-json"
+)
+
+const (
+	fieldTypeString = `string`
+	fieldTypeInt    = `integer`
+	fieldTypeNumber = `number`
+	fieldTypeBool   = `boolean`
+	fieldTypeObject = `object`
+	fieldTypeArray  = `array`
+
+	fieldSourcePath  = `path`
+	fieldSourceQuery = `query`
+	fieldSourceForm  = `form`
+)
+
+// Field validation rule of a request field, created using FieldString, FieldInt, FieldNumber, FieldBool, FieldObject or FieldArray
+type Field struct {
+	name     string
+	typ      string
+	required bool
+	min      *float64
+	max      *float64
+	pattern  *regexp.Regexp
+	enum     []interface{}
+	fields   []*Field
+	items    *Field
+	rules    []func(interface{}) error
+	source   string
+}
+
+// FieldError validation error of a single field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func FieldString(name string) *Field {
+	return &Field{name: name, typ: fieldTypeString}
+}
+
+func FieldInt(name string) *Field {
+	return &Field{name: name, typ: fieldTypeInt}
+}
+
+func FieldNumber(name string) *Field {
+	return &Field{name: name, typ: fieldTypeNumber}
+}
+
+func FieldBool(name string) *Field {
+	return &Field{name: name, typ: fieldTypeBool}
+}
+
+// FieldObject nested object with its own fields
+func FieldObject(name string, fields ...*Field) *Field {
+	return &Field{name: name, typ: fieldTypeObject, fields: fields}
+}
+
+// FieldArray array with each item validated using item, name of item is ignored
+func FieldArray(name string, item *Field) *Field {
+	return &Field{name: name, typ: fieldTypeArray, items: item}
+}
+
+func (f *Field) Required() *Field {
+	f.required = true
+	return f
+}
+
+// Min minimum value for number, minimum length for string and array
+func (f *Field) Min(min float64) *Field {
+	f.min = &min
+	return f
+}
+
+// Max maximum value for number, maximum length for string and array
+func (f *Field) Max(max float64) *Field {
+	f.max = &max
+	return f
+}
+
+// Pattern regex that string value must match, panic if regex invalid
+func (f *Field) Pattern(pattern string) *Field {
+	f.pattern = regexp.MustCompile(pattern)
+	return f
+}
+
+// Enum allowed values
+func (f *Field) Enum(values ...interface{}) *Field {
+	f.enum = values
+	return f
+}
+
+// Rule add custom validation, returned error message used as field error
+func (f *Field) Rule(fn func(value interface{}) error) *Field {
+	f.rules = append(f.rules, fn)
+	return f
+}
+
+func (f *Field) validate(path string, value interface{}) []FieldError {
+	if value == nil {
+		if f.required {
+			return []FieldError{{path, `required`}}
+		}
+		return nil
+	}
+	errs := []FieldError{}
+	var size *float64
+	switch f.typ {
+	case fieldTypeString:
+		str, ok := value.(string)
+		if !ok {
+			return []FieldError{{path, `must be a string`}}
+		}
+		l := float64(len([]rune(str)))
+		size = &l
+		if f.pattern != nil && !f.pattern.MatchString(str) {
+			errs = append(errs, FieldError{path, `invalid format`})
+		}
+	case fieldTypeInt, fieldTypeNumber:
+		num, ok := toFloat(value)
+		if !ok {
+			return []FieldError{{path, `must be of type ` + f.typ}}
+		}
+		if f.typ == fieldTypeInt && num != math.Trunc(num) {
+			return []FieldError{{path, `must be an integer`}}
+		}
+		size = &num
+	case fieldTypeBool:
+		if _, ok := toBool(value); !ok {
+			return []FieldError{{path, `must be a boolean`}}
+		}
+	case fieldTypeObject:
+		obj, ok := toObject(value)
+		if !ok {
+			return []FieldError{{path, `must be an object`}}
+		}
+		for _, field := range f.fields {
+			errs = append(errs, field.validate(path+`.`+field.name, obj[field.name])...)
+		}
+	case fieldTypeArray:
+		arr, ok := toArray(value)
+		if !ok {
+			return []FieldError{{path, `must be an array`}}
+		}
+		l := float64(len(arr))
+		size = &l
+		if f.items != nil {
+			for i, item := range arr {
+				errs = append(errs, f.items.validate(fmt.Sprintf(`%s[%d]`, path, i), item)...)
+			}
+		}
+	}
+	if size != nil {
+		if f.min != nil && *size < *f.min {
+			errs = append(errs, FieldError{path, fmt.Sprintf(`must be at least %v`, *f.min)})
+		}
+		if f.max != nil && *size > *f.max {
+			errs = append(errs, FieldError{path, fmt.Sprintf(`must be at most %v`, *f.max)})
+		}
+	}
+	if len(f.enum) > 0 {
+		found := false
+		for _, val := range f.enum {
+			if fmt.Sprint(val) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, FieldError{path, fmt.Sprintf(`must be one of %v`, f.enum)})
+		}
+	}
+	for _, rule := range f.rules {
+		if e := rule(value); e != nil {
+			errs = append(errs, FieldError{path, e.Error()})
+		}
+	}
+	return errs
+}
+
+// schema return JSON schema of field for OpenAPI document
+func (f *Field) schema() gojson.Object {
+	schema := gojson.Object{`type`: f.typ}
+	switch f.typ {
+	case fieldTypeString:
+		if f.min != nil {
+			schema.Put(`minLength`, *f.min)
+		}
+		if f.max != nil {
+			schema.Put(`maxLength`, *f.max)
+		}
+		if f.pattern != nil {
+			schema.Put(`pattern`, f.pattern.String())
+		}
+	case fieldTypeInt, fieldTypeNumber:
+		if f.min != nil {
+			schema.Put(`minimum`, *f.min)
+		}
+		if f.max != nil {
+			schema.Put(`maximum`, *f.max)
+		}
+	case fieldTypeObject:
+		schema = fieldsSchema(f.fields)
+	case fieldTypeArray:
+		if f.items != nil {
+			schema.Put(`items`, f.items.schema())
+		}
+		if f.min != nil {
+			schema.Put(`minItems`, *f.min)
+		}
+		if f.max != nil {
+			schema.Put(`maxItems`, *f.max)
+		}
+	}
+	if len(f.enum) > 0 {
+		schema.Put(`enum`, f.enum)
+	}
+	return schema
+}
+
+func fieldsSchema(fields []*Field) gojson.Object {
+	props := gojson.Object{}
+	required := []string{}
+	for _, field := range fields {
+		props.Put(field.name, field.schema())
+		if field.required {
+			required = append(required, field.name)
+		}
+	}
+	schema := gojson.Object{`type`: fieldTypeObject, `properties`: props}
+	if len(required) > 0 {
+		schema.Put(`required`, required)
+	}
+	return schema
+}
+
+// value return raw request value of field, fields without source are read from JSON body or query string
+func (f *Field) value(ctx *Context) interface{} {
+	switch f.source {
+	case fieldSourcePath:
+		if val, ok := ctx.params[f.name]; ok {
+			return val
+		}
+	case fieldSourceQuery:
+		if query := ctx.URL().Query(); query.Has(f.name) {
+			return query.Get(f.name)
+		}
+	case fieldSourceForm:
+		if val := ctx.fastCtx.FormValue(f.name); val != nil {
+			return string(val)
+		}
+	default:
+		return ctx.req.get(f.name)
+	}
+	return nil
+}
+
+// validateRequest validate request fields, respond with status 400 listing all failing fields
+func validateRequest(ctx *Context, fields []*Field) error {
+	errs := []FieldError{}
+	for _, field := range fields {
+		errs = append(errs, field.validate(field.name, field.value(ctx))...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	ctx.resp.put(`errors`, errs)
+	return ctx.StatusBadRequest(`validation failed`)
+}
+
+// coerceParam convert param value to typ, string values from query string are parsed
+func coerceParam(name, typ string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch typ {
+	case `int`:
+		num, ok := toFloat(value)
+		if !ok || num != math.Trunc(num) {
+			return nil, fmt.Errorf(`parameter %s must be an integer`, name)
+		}
+		return int64(num), nil
+	case `float`:
+		num, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf(`parameter %s must be a number`, name)
+		}
+		return num, nil
+	case `bool`:
+		b, ok := toBool(value)
+		if !ok {
+			return nil, fmt.Errorf(`parameter %s must be a boolean`, name)
+		}
+		return b, nil
+	case `string`:
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+		return fmt.Sprint(value), nil
+	}
+	return value, nil
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch val := value.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case json.Number:
+		f, e := val.Float64()
+		return f, e == nil
+	case string:
+		f, e := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, e == nil
+	}
+	return 0, false
+}
+
+func toBool(value interface{}) (bool, bool) {
+	switch val := value.(type) {
+	case bool:
+		return val, true
+	case string:
+		b, e := strconv.ParseBool(val)
+		return b, e == nil
+	}
+	return false, false
+}
+
+func toObject(value interface{}) (map[string]interface{}, bool) {
+	switch val := value.(type) {
+	case gojson.Object:
+		return val, true
+	case map[string]interface{}:
+		return val, true
+	}
+	return nil, false
+}
+
+func toArray(value interface{}) ([]interface{}, bool) {
+	switch val := value.(type) {
+	case []interface{}:
+		return val, true
+	case []gojson.Object:
+		arr := make([]interface{}, len(val))
+		for i, v := range val {
+			arr[i] = v
+		}
+		return arr, true
+	}
+	return nil, false
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WatchRoutes load routes like LoadRoutes, then check files every interval and reload routes when any file added, removed or changed. Reloaded routes replace previous ones atomically, in-flight requests finish using previous routes. Invalid files are logged and previous routes kept. Call returned func to stop watching.
+func (s *Server) WatchRoutes(interval time.Duration, paths ...string) (func(), error) {
+	set, e := parseRouteFiles(paths)
+	if e != nil {
+		return nil, e
+	}
+	loaded, e := s.applyRoutes(nil, set)
+	if e != nil {
+		return nil, e
+	}
+	sig, _ := routeFilesSignature(paths)
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			newSig, e := routeFilesSignature(paths)
+			if e != nil {
+				s.logger.W(`unable to watch routes:`, e)
+				continue
+			}
+			if newSig == sig {
+				continue
+			}
+			sig = newSig
+			set, e := parseRouteFiles(paths)
+			if e != nil {
+				s.logger.E(`reload routes failed, previous routes kept:`, e)
+				continue
+			}
+			reloaded, e := s.applyRoutes(loaded, set)
+			if e != nil {
+				s.logger.E(`reload routes failed, previous routes kept:`, e)
+				continue
+			}
+			s.logger.I(reloadDiff(loaded, reloaded))
+			loaded = reloaded
+		}
+	}()
+	return func() { close(stop) }, nil
+}
+
+// routeFilesSignature return string changed whenever route file added, removed or modified
+func routeFilesSignature(paths []string) (string, error) {
+	files, e := routeFiles(paths)
+	if e != nil {
+		return ``, e
+	}
+	sb := strings.Builder{}
+	for _, file := range files {
+		stat, e := os.Stat(file)
+		if e != nil {
+			return ``, e
+		}
+		fmt.Fprintf(&sb, "%s|%d|%d\n", file, stat.Size(), stat.ModTime().UnixNano())
+	}
+	return sb.String(), nil
+}
+
+func reloadDiff(prev, next *loadedRoutes) string {
+	diff := func(a, b map[string]struct{}) []string {
+		keys := []string{}
+		for key := range a {
+			if _, ok := b[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	return fmt.Sprintf(`Routes reloaded, added: %v, removed: %v, files added: %v, files removed: %v`,
+		diff(next.routes, prev.routes), diff(prev.routes, next.routes),
+		diff(next.files, prev.files), diff(prev.files, next.files))
+}
@@ -1,12 +1,158 @@
 package api
 
-import "github.com/eqto/api-server/websocket"
+import (
+	"strings"
+	"time"
 
+	"github.com/eqto/api-server/websocket"
+)
+
+// Websocket handlers and options of websocket route, each route has its own client registry
 type Websocket struct {
-	wsServ *websocket.Server
+	wsServ    *websocket.Server
+	route     *Route
+	origins   *originMatcher
+	onUpgrade func(ctx *Context, protocols []string) (string, error)
+}
+
+// AllowOrigins reject upgrade request from browser with origin not in origins with status 403. Origin can be "*", wildcard (ex: https://*.example.com) or regex starting with ^. Invalid regex logged as error and all origins rejected.
+func (w *Websocket) AllowOrigins(origins ...string) *Websocket {
+	m, e := newOriginMatcher(origins)
+	if e != nil {
+		if w.route.logger != nil {
+			w.route.logger.E(e)
+		}
+		m = &originMatcher{}
+	}
+	w.origins = m
+	return w
+}
+
+// MaxMessageSize close connection of client sending message larger than size bytes, 0 for unlimited. Frame payload limited to 1 MiB unless size is larger.
+func (w *Websocket) MaxMessageSize(size int) *Websocket {
+	w.wsServ.SetMaxMessageSize(size)
+	return w
+}
+
+// MessageRate close connection of client sending more than rate messages per second, 0 for unlimited
+func (w *Websocket) MessageRate(rate int) *Websocket {
+	w.wsServ.SetMessageRate(rate)
+	return w
+}
+
+// Secure run secure middlewares before upgrade, upgrade rejected if any of them return error
+func (w *Websocket) Secure() *Websocket {
+	w.route.Secure()
+	return w
+}
+
+// OnUpgrade set func called before upgrade with subprotocols requested by client. Return selected subprotocol, empty for none, or error to reject upgrade with status set on ctx, default 403.
+func (w *Websocket) OnUpgrade(fn func(ctx *Context, protocols []string) (string, error)) *Websocket {
+	w.onUpgrade = fn
+	return w
+}
+
+// Heartbeat send ping every interval, close connection of client not responding pong within timeout
+func (w *Websocket) Heartbeat(interval, timeout time.Duration) *Websocket {
+	w.wsServ.SetHeartbeat(interval, timeout)
+	return w
+}
+
+// IdleTimeout close connection of client not sending any message within timeout
+func (w *Websocket) IdleTimeout(timeout time.Duration) *Websocket {
+	w.wsServ.SetIdleTimeout(timeout)
+	return w
+}
+
+// Compression negotiate per-message compression (permessage-deflate) with clients offering it, messages of at least 64 bytes sent compressed
+func (w *Websocket) Compression() *Websocket {
+	w.wsServ.SetCompression(true)
+	return w
+}
+
+// WriteTimeout close connection when writing a message take longer than timeout
+func (w *Websocket) WriteTimeout(timeout time.Duration) *Websocket {
+	w.wsServ.SetWriteTimeout(timeout)
+	return w
+}
+
+// SendQueue set size of per client send queue, default 256, and action when queue of slow client full, default websocket.QueueDisconnect
+func (w *Websocket) SendQueue(size int, policy websocket.QueuePolicy) *Websocket {
+	w.wsServ.SetSendQueue(size, policy)
+	return w
 }
 
 func (w *Websocket) OnAccept(fn func(client *websocket.Client)) *Websocket {
 	w.wsServ.OnAccept(fn)
 	return w
 }
+
+// OnClose set func called after client disconnected, err is nil if closed normally
+func (w *Websocket) OnClose(fn func(client *websocket.Client, err error)) *Websocket {
+	w.wsServ.OnClose(fn)
+	return w
+}
+
+// OnError set func called when client connection error
+func (w *Websocket) OnError(fn func(client *websocket.Client, err error)) *Websocket {
+	w.wsServ.OnError(fn)
+	return w
+}
+
+// Server return websocket server to address clients, rooms and broadcast
+func (w *Websocket) Server() *websocket.Server {
+	return w.wsServ
+}
+
+// Broadcast write data to clients of all websocket routes
+func (s *Server) Broadcast(data []byte) {
+	for _, wsServ := range s.wsServs {
+		wsServ.Broadcast(data)
+	}
+}
+
+// BroadcastRoom write data to clients of room in all websocket routes
+func (s *Server) BroadcastRoom(room string, data []byte) {
+	for _, wsServ := range s.wsServs {
+		wsServ.BroadcastRoom(room, data)
+	}
+}
+
+// WebsocketSession return session of client upgrade request
+func WebsocketSession(client *websocket.Client) *Session {
+	sess, _ := client.Handshake().Session.(*Session)
+	return sess
+}
+
+func (w *Websocket) upgrade(ctx *Context) error {
+	if origin := ctx.req.Header().Get(`Origin`); w.origins != nil && origin != `` && !w.origins.allowed(origin) {
+		return ctx.StatusForbidden(`origin not allowed: ` + origin)
+	}
+	hs := &websocket.Handshake{
+		RemoteIP: ctx.RemoteIP(),
+		Header:   map[string]string{},
+		Session:  ctx.Session(),
+		Values:   ctx.values,
+	}
+	ctx.req.fastCtx.Request.Header.VisitAll(func(key, value []byte) {
+		hs.Header[strings.ToLower(string(key))] = string(value)
+	})
+	if w.onUpgrade != nil {
+		protocols := []string{}
+		for _, proto := range strings.Split(ctx.req.Header().Get(`Sec-WebSocket-Protocol`), `,`) {
+			if proto = strings.TrimSpace(proto); proto != `` {
+				protocols = append(protocols, proto)
+			}
+		}
+		proto, e := w.onUpgrade(ctx, protocols)
+		if e != nil {
+			if ctx.resp.httpResp.StatusCode() == StatusOK {
+				ctx.StatusForbidden(e.Error())
+			}
+			return e
+		}
+		hs.Protocol = proto
+	}
+	w.wsServ.Upgrade(ctx.fastCtx, hs)
+	return nil
+}
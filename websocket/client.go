@@ -1,18 +1,44 @@
 package websocket
 
 import (
+	"io"
 	"sync"
+	"time"
 
 	"github.com/dgrr/websocket"
 )
 
 type Client struct {
+	s         *Server
 	conn      *websocket.Conn
+	handshake *Handshake
 	writer    *Writer
 	onMessage func(bool, []byte, *Writer)
 
 	preBuffer  []bufferMsg
 	bufferLock sync.Mutex
+
+	values    map[string]interface{}
+	rooms     map[string]struct{}
+	valueLock sync.RWMutex
+
+	// messages received in current one second window, accessed from connection goroutine only
+	rateCount  int
+	rateWindow time.Time
+
+	// message assembled from frames when compression enabled, accessed from connection goroutine only
+	message           []byte
+	messageBinary     bool
+	messageCompressed bool
+	// closeErr status of close frame sent by client when compression enabled
+	closeErr error
+
+	queue chan []byte
+	// closing closed when close frame sent, done closed when client removed
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	stats     clientStats
 }
 
 func (c *Client) receiveMessage(isBinary bool, data []byte) {
@@ -41,12 +67,127 @@ func (c *Client) OnMessage(fn func(isBinary bool, data []byte, w *Writer)) {
 	c.onMessage = fn
 }
 
+// Write queue data to be sent to client, return error if send queue full or client closed
 func (c *Client) Write(data []byte) (int, error) {
-	return c.writer.Write(data)
+	return c.send(data)
+}
+
+// allowMessage count received message, return false if more than rate messages received in current second
+func (c *Client) allowMessage(rate int) bool {
+	now := time.Now()
+	if now.Sub(c.rateWindow) >= time.Second {
+		c.rateWindow = now
+		c.rateCount = 0
+	}
+	c.rateCount++
+	return c.rateCount <= rate
+}
+
+// Close send close frame and close connection when client reply or after closeTimeout
+func (c *Client) Close() error {
+	c.closeWith(websocket.StatusNone, ``)
+	return nil
+}
+
+func (c *Client) closeWith(status websocket.StatusCode, reason string) {
+	c.sendClose(status, reason, closeTimeout)
+}
+
+// sendClose queue close frame to connection writer, connection closed after wait unless client removed before. Conn.CloseDetail close connection before its close frame written, so it only used after close frame had time to be written. Frame queued in background since connection writer of slow client may be full.
+func (c *Client) sendClose(status websocket.StatusCode, reason string, wait time.Duration) {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		fr := websocket.AcquireFrame()
+		fr.SetClose()
+		fr.SetStatus(status)
+		fr.SetFin()
+		io.WriteString(fr, reason)
+		go func() {
+			c.conn.WriteFrame(fr)
+			select {
+			case <-c.done:
+			case <-time.After(wait):
+				c.conn.CloseDetail(status, reason)
+			}
+		}()
+	})
+}
+
+// closeByPeer handle close frame sent by client, replied if server not closing yet
+func (c *Client) closeByPeer(status websocket.StatusCode, reason string) {
+	if status != websocket.StatusNone {
+		c.closeErr = websocket.Error{Status: status, Reason: reason}
+	}
+	select {
+	case <-c.closing: //reply of close frame sent by server
+		c.conn.CloseDetail(status, ``)
+	default:
+		c.sendClose(status, ``, closeReplyDelay)
+	}
 }
 
-func newClient(conn *websocket.Conn) *Client {
-	return &Client{conn: conn, writer: &Writer{conn: conn}}
+// SetValue set metadata of client
+func (c *Client) SetValue(name string, value interface{}) {
+	c.valueLock.Lock()
+	defer c.valueLock.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[name] = value
+}
+
+// GetValue return metadata of client, nil if not exists
+func (c *Client) GetValue(name string) interface{} {
+	c.valueLock.RLock()
+	defer c.valueLock.RUnlock()
+	return c.values[name]
+}
+
+// Join add client to room, room created if not exists
+func (c *Client) Join(room string) {
+	c.s.join(c, room)
+}
+
+// Leave remove client from room, room removed when empty
+func (c *Client) Leave(room string) {
+	c.s.leave(c, room)
+}
+
+// Rooms return rooms joined by client
+func (c *Client) Rooms() []string {
+	c.valueLock.RLock()
+	defer c.valueLock.RUnlock()
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+func newClient(s *Server, conn *websocket.Conn) *Client {
+	hs, ok := conn.UserValue(handshakeKey).(*Handshake)
+	if !ok {
+		hs = &Handshake{}
+	}
+	values := make(map[string]interface{}, len(hs.Values))
+	for key, val := range hs.Values {
+		values[key] = val
+	}
+	queueSize := s.queueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	conn.WriteTimeout = s.writeTimeout
+	// library reject frame larger than MaxPayloadSize, default 1 MiB, smaller message limit checked when message received to close with status 1009
+	if s.maxMessageSize > websocket.DefaultPayloadSize {
+		conn.MaxPayloadSize = uint64(s.maxMessageSize)
+	}
+	c := &Client{s: s, conn: conn, handshake: hs, values: values, queue: make(chan []byte, queueSize), closing: make(chan struct{}), done: make(chan struct{})}
+	c.writer = &Writer{client: c}
+	now := time.Now().UnixNano()
+	c.stats.lastMessage = now
+	c.stats.pingAt = now
+	return c
 }
 
 type bufferMsg struct {
@@ -0,0 +1,187 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrr/websocket"
+)
+
+const (
+	// deflateResponse permessage-deflate accepted without context takeover, each message compressed independently
+	deflateResponse = `permessage-deflate; server_no_context_takeover; client_no_context_takeover`
+	// compressMinSize smaller message sent uncompressed
+	compressMinSize = 64
+	// closeReplyDelay wait for close frame replied to client to be written before connection closed
+	closeReplyDelay = 100 * time.Millisecond
+)
+
+var (
+	// deflateTail removed from end of compressed message, added back with final empty block before inflated
+	deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+	inflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+	errMessageTooBig = errors.New(`message too big`)
+
+	flateWriterPool = sync.Pool{}
+)
+
+// SetCompression negotiate permessage-deflate with clients offering it. Frames read by this package instead of websocket library, since library drop compressed bit of frame. Message limited to max message size, 1 MiB if unlimited.
+func (s *Server) SetCompression(enable bool) {
+	s.compression = enable
+	if enable {
+		s.ws.HandleFrame(s.handleFrame)
+	}
+}
+
+// acceptDeflate return true if any permessage-deflate offer of Sec-WebSocket-Extensions header acceptable. Offer requiring server window smaller than 15 bits declined, compress/flate always use 32 KiB window.
+func acceptDeflate(header string) bool {
+	for _, offer := range strings.Split(header, `,`) {
+		params := strings.Split(offer, `;`)
+		if strings.TrimSpace(params[0]) != `permessage-deflate` {
+			continue
+		}
+		accepted := true
+		for _, param := range params[1:] {
+			name, value := strings.TrimSpace(param), ``
+			if idx := strings.Index(name, `=`); idx >= 0 {
+				name, value = strings.TrimSpace(name[:idx]), strings.Trim(strings.TrimSpace(name[idx+1:]), `"`)
+			}
+			switch name {
+			case `server_no_context_takeover`, `client_no_context_takeover`, `client_max_window_bits`:
+			case `server_max_window_bits`:
+				accepted = accepted && value == `15`
+			default:
+				accepted = false
+			}
+		}
+		if accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// deflate compress message, trailing empty block of flush removed
+func deflate(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, ok := flateWriterPool.Get().(*flate.Writer)
+	if ok {
+		w.Reset(buf)
+	} else {
+		var e error
+		if w, e = flate.NewWriter(buf, flate.BestSpeed); e != nil {
+			return nil, e
+		}
+	}
+	defer flateWriterPool.Put(w)
+	if _, e := w.Write(data); e != nil {
+		return nil, e
+	}
+	if e := w.Flush(); e != nil {
+		return nil, e
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+// inflate decompress message, return errMessageTooBig if inflated larger than limit
+func inflate(data []byte, limit int) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(inflateTail)))
+	defer r.Close()
+	buf := &bytes.Buffer{}
+	if _, e := io.Copy(buf, io.LimitReader(r, int64(limit)+1)); e != nil {
+		return nil, e
+	}
+	if buf.Len() > limit {
+		return nil, errMessageTooBig
+	}
+	return buf.Bytes(), nil
+}
+
+// handleFrame handle frames of route with compression
+func (s *Server) handleFrame(c *websocket.Conn, fr *websocket.Frame) {
+	defer websocket.ReleaseFrame(fr)
+	if fr.IsMasked() {
+		fr.Unmask()
+	}
+	client := s.Client(c.ID())
+	if client == nil {
+		return
+	}
+	switch {
+	case fr.IsPing():
+		pong := websocket.AcquireFrame()
+		pong.SetCode(websocket.CodePong)
+		pong.SetPayload(fr.Payload())
+		pong.SetFin()
+		c.WriteFrame(pong)
+	case fr.IsPong():
+		s.handlePong(c, fr.Payload())
+	case fr.IsClose():
+		client.closeByPeer(fr.Status(), string(fr.Payload()))
+	default:
+		client.receiveFrame(fr)
+	}
+}
+
+// receiveFrame assemble message from data frames, compressed message inflated. Called from connection goroutine only.
+func (c *Client) receiveFrame(fr *websocket.Frame) {
+	if fr.HasRSV2() || fr.HasRSV3() || fr.HasRSV1() && (!c.handshake.compress || fr.IsContinuation()) {
+		c.closeWith(websocket.StatusProtocolError, `unexpected reserved bit`)
+		return
+	}
+	if !fr.IsContinuation() {
+		c.message = nil
+		c.messageBinary = fr.Code() == websocket.CodeBinary
+		c.messageCompressed = fr.HasRSV1()
+	}
+	limit := c.s.maxMessageSize
+	if limit <= 0 {
+		limit = websocket.DefaultPayloadSize
+	}
+	if c.message = append(c.message, fr.Payload()...); len(c.message) > limit {
+		c.closeWith(websocket.StatusTooBig, errMessageTooBig.Error())
+		return
+	}
+	if !fr.IsFin() {
+		return
+	}
+	data := c.message
+	c.message = nil
+	if c.messageCompressed {
+		inflated, e := inflate(data, limit)
+		if e == errMessageTooBig {
+			c.closeWith(websocket.StatusTooBig, errMessageTooBig.Error())
+			return
+		} else if e != nil {
+			c.closeWith(websocket.StatusProtocolError, `invalid compressed message`)
+			return
+		}
+		data = inflated
+	}
+	c.s.handleData(c.conn, c.messageBinary, data)
+}
+
+// write write message to connection, compressed when negotiated and message not smaller than compressMinSize
+func (c *Client) write(msg []byte) {
+	if !c.handshake.compress || len(msg) < compressMinSize {
+		c.conn.Write(msg)
+		return
+	}
+	data, e := deflate(msg)
+	if e != nil {
+		c.conn.Write(msg)
+		return
+	}
+	fr := websocket.AcquireFrame()
+	fr.SetFin()
+	fr.SetText()
+	fr.SetRSV1()
+	fr.SetPayload(data)
+	c.conn.WriteFrame(fr)
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAcceptDeflate(t *testing.T) {
+	tests := map[string]bool{
+		``:                   false,
+		`permessage-deflate`: true,
+		`permessage-deflate; client_max_window_bits`:                                true,
+		`permessage-deflate; server_no_context_takeover; client_max_window_bits=10`: true,
+		`permessage-deflate; server_max_window_bits=15`:                             true,
+		`permessage-deflate; server_max_window_bits="15"`:                           true,
+		`permessage-deflate; server_max_window_bits=10`:                             false,
+		`permessage-deflate; unknown_param`:                                         false,
+		`x-webkit-deflate-frame`:                                                    false,
+		`permessage-deflate; server_max_window_bits=10, permessage-deflate`:         true,
+	}
+	for header, expected := range tests {
+		if accepted := acceptDeflate(header); accepted != expected {
+			t.Errorf(`%q: expected accepted %v, got %v`, header, expected, accepted)
+		}
+	}
+}
+
+func TestDeflateRoundTrip(t *testing.T) {
+	for _, msg := range [][]byte{{}, []byte(`a`), []byte(strings.Repeat(`hello websocket `, 1000))} {
+		compressed, e := deflate(msg)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if bytes.HasSuffix(compressed, deflateTail) {
+			t.Errorf(`compressed message of %d bytes end with flush marker`, len(msg))
+		}
+		inflated, e := inflate(compressed, len(msg))
+		if e != nil {
+			t.Fatal(e)
+		}
+		if !bytes.Equal(inflated, msg) {
+			t.Errorf(`round trip of %d bytes returned %d bytes`, len(msg), len(inflated))
+		}
+	}
+}
+
+func TestInflateLimit(t *testing.T) {
+	compressed, e := deflate(make([]byte, 10000))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e := inflate(compressed, 9999); e != errMessageTooBig {
+		t.Errorf(`expected message too big, got %v`, e)
+	}
+	if _, e := inflate([]byte(`not deflate`), 100); e == nil {
+		t.Error(`expected error for invalid compressed data`)
+	}
+}
@@ -0,0 +1,44 @@
+package websocket
+
+import "strings"
+
+const handshakeKey = `api_ws_handshake`
+
+// Handshake data of upgrade request carried to client
+type Handshake struct {
+	RemoteIP string
+	// Header request headers keyed by lowercase name
+	Header map[string]string
+	// Session *api.Session of upgrade request
+	Session interface{}
+	// Values values set on request context, copied as client values
+	Values   map[string]interface{}
+	Protocol string
+	// compress permessage-deflate negotiated
+	compress bool
+}
+
+// Handshake return upgrade request data of client
+func (c *Client) Handshake() *Handshake {
+	return c.handshake
+}
+
+// RemoteIP return remote IP of upgrade request
+func (c *Client) RemoteIP() string {
+	return c.handshake.RemoteIP
+}
+
+// Header return header value of upgrade request
+func (c *Client) Header(name string) string {
+	return c.handshake.Header[strings.ToLower(name)]
+}
+
+// Protocol return subprotocol selected on upgrade
+func (c *Client) Protocol() string {
+	return c.handshake.Protocol
+}
+
+// Compressed return true if permessage-deflate negotiated on upgrade
+func (c *Client) Compressed() bool {
+	return c.handshake.compress
+}
@@ -0,0 +1,191 @@
+package websocket
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgrr/websocket"
+)
+
+// QueuePolicy action when client send queue full
+type QueuePolicy uint8
+
+const (
+	// QueueDisconnect close connection of slow client with status 1008
+	QueueDisconnect QueuePolicy = iota
+	// QueueDrop drop message, Write return error
+	QueueDrop
+)
+
+const (
+	defaultQueueSize = 256
+	// closeTimeout wait for client to reply close frame before connection closed
+	closeTimeout = 5 * time.Second
+)
+
+var (
+	errQueueFull    = errors.New(`send queue full`)
+	errClientClosed = errors.New(`client already closed`)
+)
+
+// Stats counters of client connection
+type Stats struct {
+	MessagesIn  uint64
+	MessagesOut uint64
+	BytesIn     uint64
+	BytesOut    uint64
+	// Dropped messages not sent because send queue full
+	Dropped uint64
+	// Queued messages waiting in send queue
+	Queued int
+	// LastMessage time of last message received
+	LastMessage time.Time
+	// Latency round trip of last ping, 0 if no pong received yet
+	Latency time.Duration
+}
+
+// clientStats updated atomically
+type clientStats struct {
+	messagesIn  uint64
+	messagesOut uint64
+	bytesIn     uint64
+	bytesOut    uint64
+	dropped     uint64
+	lastMessage int64
+	latency     int64
+	pingAt      int64
+	waitPong    int32
+}
+
+// SetHeartbeat send ping every interval, close connection of client not responding pong within timeout. 0 interval disable ping.
+func (s *Server) SetHeartbeat(interval, timeout time.Duration) {
+	s.pingInterval = interval
+	s.pongTimeout = timeout
+}
+
+// SetIdleTimeout close connection of client not sending any message within timeout, 0 to disable
+func (s *Server) SetIdleTimeout(timeout time.Duration) {
+	s.idleTimeout = timeout
+}
+
+// SetWriteTimeout close connection when writing a message take longer than timeout, 0 to disable
+func (s *Server) SetWriteTimeout(timeout time.Duration) {
+	s.writeTimeout = timeout
+}
+
+// SetSendQueue set size of per client send queue, default 256, and action when queue full, default QueueDisconnect
+func (s *Server) SetSendQueue(size int, policy QueuePolicy) {
+	s.queueSize = size
+	s.queuePolicy = policy
+}
+
+// Stats return counters of client connection
+func (c *Client) Stats() Stats {
+	stats := Stats{
+		MessagesIn:  atomic.LoadUint64(&c.stats.messagesIn),
+		MessagesOut: atomic.LoadUint64(&c.stats.messagesOut),
+		BytesIn:     atomic.LoadUint64(&c.stats.bytesIn),
+		BytesOut:    atomic.LoadUint64(&c.stats.bytesOut),
+		Dropped:     atomic.LoadUint64(&c.stats.dropped),
+		Queued:      len(c.queue),
+		Latency:     time.Duration(atomic.LoadInt64(&c.stats.latency)),
+	}
+	if last := atomic.LoadInt64(&c.stats.lastMessage); last > 0 {
+		stats.LastMessage = time.Unix(0, last)
+	}
+	return stats
+}
+
+// send copy data to send queue, data may be reused by caller after returned
+func (c *Client) send(data []byte) (int, error) {
+	// checked before queued, select pick randomly when queue has room after closed
+	select {
+	case <-c.done:
+		return 0, errClientClosed
+	case <-c.closing:
+		return 0, errClientClosed
+	default:
+	}
+	select {
+	case c.queue <- append([]byte(nil), data...):
+		return len(data), nil
+	default:
+	}
+	atomic.AddUint64(&c.stats.dropped, 1)
+	if c.s.queuePolicy == QueueDisconnect {
+		c.closeWith(websocket.StatusViolation, errQueueFull.Error())
+	}
+	return 0, errQueueFull
+}
+
+// writeLoop write queued messages to connection until close frame sent or client removed
+func (c *Client) writeLoop() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.closing:
+			return
+		case msg := <-c.queue:
+			c.write(msg)
+			atomic.AddUint64(&c.stats.messagesOut, 1)
+			atomic.AddUint64(&c.stats.bytesOut, uint64(len(msg)))
+		}
+	}
+}
+
+func (c *Client) received(data []byte) {
+	atomic.AddUint64(&c.stats.messagesIn, 1)
+	atomic.AddUint64(&c.stats.bytesIn, uint64(len(data)))
+	atomic.StoreInt64(&c.stats.lastMessage, time.Now().UnixNano())
+}
+
+func (c *Client) pong() {
+	if atomic.CompareAndSwapInt32(&c.stats.waitPong, 1, 0) {
+		atomic.StoreInt64(&c.stats.latency, time.Now().UnixNano()-atomic.LoadInt64(&c.stats.pingAt))
+	}
+}
+
+// heartbeat send ping and close connection of dead or idle client until client removed
+func (c *Client) heartbeat() {
+	tick := time.Duration(0)
+	for _, d := range []time.Duration{c.s.pingInterval, c.s.pongTimeout, c.s.idleTimeout} {
+		if d > 0 && (tick == 0 || d < tick) {
+			tick = d
+		}
+	}
+	if tick == 0 || (c.s.pingInterval <= 0 && c.s.idleTimeout <= 0) {
+		return
+	}
+	ticker := time.NewTicker(tick / 2)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-c.done:
+			return
+		case <-c.closing:
+			return
+		case now = <-ticker.C:
+		}
+		if c.s.idleTimeout > 0 && now.Sub(time.Unix(0, atomic.LoadInt64(&c.stats.lastMessage))) > c.s.idleTimeout {
+			c.closeWith(websocket.StatusGoAway, `idle timeout`)
+			return
+		}
+		if c.s.pingInterval <= 0 {
+			continue
+		}
+		pingAt := time.Unix(0, atomic.LoadInt64(&c.stats.pingAt))
+		if atomic.LoadInt32(&c.stats.waitPong) == 1 {
+			if c.s.pongTimeout > 0 && now.Sub(pingAt) > c.s.pongTimeout {
+				c.closeWith(websocket.StatusGoAway, `pong timeout`)
+				return
+			}
+		} else if now.Sub(pingAt) >= c.s.pingInterval {
+			atomic.StoreInt64(&c.stats.pingAt, now.UnixNano())
+			atomic.StoreInt32(&c.stats.waitPong, 1)
+			c.conn.Ping(nil)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package websocket
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dgrr/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// dialSlowClient serve s and connect client not reading any frame until test read it, return server side client
+func dialSlowClient(t *testing.T, s *Server) (*Client, *websocket.Client, net.Conn) {
+	t.Helper()
+	accepted := make(chan *Client, 1)
+	s.OnAccept(func(client *Client) { accepted <- client })
+	ln := fasthttputil.NewInmemoryListener()
+	serv := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) { s.Upgrade(ctx, nil) }}
+	go serv.Serve(ln)
+	t.Cleanup(func() { ln.Close() })
+
+	conn, e := ln.Dial()
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { conn.Close() })
+	wsClient, e := websocket.MakeClient(conn, `http://test/ws`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	select {
+	case client := <-accepted:
+		return client, wsClient, conn
+	case <-time.After(time.Second):
+		t.Fatal(`websocket client not accepted`)
+	}
+	return nil, nil, nil
+}
+
+// fillQueue write messages until send queue of client full, return nil if queue never full
+func fillQueue(client *Client) error {
+	msg := make([]byte, 1024)
+	for i := 0; i < 10000; i++ {
+		if _, e := client.Write(msg); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func TestSendQueueDrop(t *testing.T) {
+	s := NewServer()
+	s.SetSendQueue(1, QueueDrop)
+	client, wsClient, _ := dialSlowClient(t, s)
+
+	if e := fillQueue(client); e != errQueueFull {
+		t.Fatalf(`expected errQueueFull, got %v`, e)
+	}
+	if _, e := client.Write([]byte(`dropped`)); e != errQueueFull {
+		t.Errorf(`expected errQueueFull for second write, got %v`, e)
+	}
+	if stats := client.Stats(); stats.Dropped != 2 || stats.Queued != 1 {
+		t.Errorf(`expected 2 dropped and 1 queued, got %d dropped and %d queued`, stats.Dropped, stats.Queued)
+	}
+
+	// client still open, message sent once client read queued messages
+	go func() {
+		fr := websocket.AcquireFrame()
+		defer websocket.ReleaseFrame(fr)
+		for {
+			if _, e := wsClient.ReadFrame(fr); e != nil || fr.IsClose() {
+				return
+			}
+			fr.Reset()
+		}
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, e := client.Write([]byte(`after`))
+		if e == nil {
+			break
+		} else if e != errQueueFull {
+			t.Fatalf(`expected client still open, got %v`, e)
+		} else if time.Now().After(deadline) {
+			t.Fatal(`send queue not drained`)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSendQueueDisconnect(t *testing.T) {
+	s := NewServer()
+	s.SetSendQueue(1, QueueDisconnect)
+	closed := make(chan error, 1)
+	s.OnClose(func(client *Client, err error) { closed <- err })
+	client, wsClient, conn := dialSlowClient(t, s)
+
+	written := make(chan error, 1)
+	go func() { written <- fillQueue(client) }()
+	select {
+	case e := <-written:
+		if e != errQueueFull {
+			t.Fatalf(`expected errQueueFull, got %v`, e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal(`Write blocked by slow client`)
+	}
+	if _, e := client.Write([]byte(`closed`)); e != errClientClosed {
+		t.Errorf(`expected errClientClosed after disconnect, got %v`, e)
+	}
+	if dropped := client.Stats().Dropped; dropped != 1 {
+		t.Errorf(`expected 1 dropped, got %d`, dropped)
+	}
+
+	fr := websocket.AcquireFrame()
+	defer websocket.ReleaseFrame(fr)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		fr.Reset()
+		if _, e := wsClient.ReadFrame(fr); e != nil {
+			t.Fatal(e)
+		}
+		if fr.IsClose() {
+			break
+		}
+	}
+	if fr.Status() != websocket.StatusViolation || string(fr.Payload()) != errQueueFull.Error() {
+		t.Errorf(`expected close with status 1008 and reason %q, got %s %q`, errQueueFull, fr.Status(), fr.Payload())
+	}
+	reply := websocket.AcquireFrame()
+	reply.SetClose()
+	reply.SetStatus(websocket.StatusViolation)
+	reply.SetFin()
+	reply.Mask()
+	wsClient.WriteFrame(reply)
+	websocket.ReleaseFrame(reply)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Error(`OnClose not called after close replied`)
+	}
+}
@@ -1,20 +1,59 @@
 package websocket
 
 import (
+	"errors"
 	"sync"
+	"time"
 
 	"github.com/dgrr/websocket"
 	"github.com/valyala/fasthttp"
 )
 
+// Server websocket server of a route
 type Server struct {
 	ws         *websocket.Server
-	clients    map[int]*Client
+	clients    map[uint64]*Client
+	rooms      map[string]map[uint64]*Client
 	clientLock sync.RWMutex
 	onAccept   func(client *Client)
+	onClose    func(client *Client, err error)
+	onError    func(client *Client, err error)
+
+	maxMessageSize int
+	messageRate    int
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	idleTimeout  time.Duration
+	writeTimeout time.Duration
+	queueSize    int
+	queuePolicy  QueuePolicy
+	compression  bool
+}
+
+// SetMaxMessageSize close connection of client sending message larger than size bytes with status 1009, 0 for unlimited. Frame payload limited to 1 MiB unless size is larger.
+func (s *Server) SetMaxMessageSize(size int) {
+	s.maxMessageSize = size
+}
+
+// SetMessageRate close connection of client sending more than rate messages per second with status 1008, 0 for unlimited
+func (s *Server) SetMessageRate(rate int) {
+	s.messageRate = rate
 }
 
-func (s *Server) Upgrade(ctx *fasthttp.RequestCtx) {
+// Upgrade upgrade request to websocket connection, hs carried to client. Subprotocol of hs and accepted permessage-deflate set as response header.
+func (s *Server) Upgrade(ctx *fasthttp.RequestCtx, hs *Handshake) {
+	if hs == nil {
+		hs = &Handshake{}
+	}
+	if hs.Protocol != `` {
+		ctx.Response.Header.Set(`Sec-WebSocket-Protocol`, hs.Protocol)
+	}
+	if s.compression && acceptDeflate(string(ctx.Request.Header.Peek(`Sec-WebSocket-Extensions`))) {
+		ctx.Response.Header.Set(`Sec-WebSocket-Extensions`, deflateResponse)
+		hs.compress = true
+	}
+	ctx.SetUserValue(handshakeKey, hs)
 	s.ws.Upgrade(ctx)
 }
 
@@ -22,30 +61,171 @@ func (s *Server) OnAccept(fn func(client *Client)) {
 	s.onAccept = fn
 }
 
-func (s *Server) handleOpen(c *websocket.Conn) {
+// OnClose set func called after client disconnected, err is nil if closed normally
+func (s *Server) OnClose(fn func(client *Client, err error)) {
+	s.onClose = fn
+}
+
+// OnError set func called when client connection error, OnClose called after connection closed
+func (s *Server) OnError(fn func(client *Client, err error)) {
+	s.onError = fn
+}
+
+// Client return connected client by id, nil if not exists
+func (s *Server) Client(id uint64) *Client {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	return s.clients[id]
+}
+
+// Clients return connected clients, use room name to return clients of room only
+func (s *Server) Clients(room ...string) []*Client {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	clients := s.clients
+	if len(room) > 0 {
+		clients = s.rooms[room[0]]
+	}
+	list := make([]*Client, 0, len(clients))
+	for _, client := range clients {
+		list = append(list, client)
+	}
+	return list
+}
+
+// Rooms return names of rooms having clients
+func (s *Server) Rooms() []string {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	rooms := make([]string, 0, len(s.rooms))
+	for room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Send write data to client by id
+func (s *Server) Send(id uint64, data []byte) error {
+	client := s.Client(id)
+	if client == nil {
+		return errors.New(`client not found`)
+	}
+	_, e := client.Write(data)
+	return e
+}
+
+// Broadcast write data to all clients
+func (s *Server) Broadcast(data []byte) {
+	for _, client := range s.Clients() {
+		client.Write(data)
+	}
+}
+
+// BroadcastRoom write data to clients of room
+func (s *Server) BroadcastRoom(room string, data []byte) {
+	for _, client := range s.Clients(room) {
+		client.Write(data)
+	}
+}
+
+func (s *Server) join(client *Client, room string) {
 	s.clientLock.Lock()
 	defer s.clientLock.Unlock()
-	client := newClient(c)
-	s.clients[int(c.ID())] = client
-	if (s.onAccept) != nil {
-		s.onAccept(client)
+	if _, ok := s.clients[client.ID()]; !ok { //already disconnected
+		return
+	}
+	if s.rooms[room] == nil {
+		s.rooms[room] = make(map[uint64]*Client)
+	}
+	s.rooms[room][client.ID()] = client
+
+	client.valueLock.Lock()
+	defer client.valueLock.Unlock()
+	if client.rooms == nil {
+		client.rooms = make(map[string]struct{})
 	}
+	client.rooms[room] = struct{}{}
 }
-func (s *Server) handleClose(c *websocket.Conn, err error) {
+
+func (s *Server) leave(client *Client, room string) {
 	s.clientLock.Lock()
 	defer s.clientLock.Unlock()
-	delete(s.clients, int(c.ID()))
+	s.removeFromRoom(client, room)
+
+	client.valueLock.Lock()
+	defer client.valueLock.Unlock()
+	delete(client.rooms, room)
 }
-func (s *Server) handleError(c *websocket.Conn, err error) {
+
+func (s *Server) removeFromRoom(client *Client, room string) {
+	if clients := s.rooms[room]; clients != nil {
+		delete(clients, client.ID())
+		if len(clients) == 0 {
+			delete(s.rooms, room)
+		}
+	}
+}
+
+// remove client from registry and its rooms, return nil if already removed
+func (s *Server) remove(c *websocket.Conn) *Client {
 	s.clientLock.Lock()
 	defer s.clientLock.Unlock()
-	delete(s.clients, int(c.ID()))
+	client, ok := s.clients[c.ID()]
+	if !ok {
+		return nil
+	}
+	delete(s.clients, c.ID())
+	close(client.done)
+	for _, room := range client.Rooms() {
+		s.removeFromRoom(client, room)
+	}
+	return client
+}
+
+func (s *Server) handleOpen(c *websocket.Conn) {
+	client := newClient(s, c)
+	s.clientLock.Lock()
+	s.clients[c.ID()] = client
+	s.clientLock.Unlock()
+	go client.writeLoop()
+	go client.heartbeat()
+	if (s.onAccept) != nil {
+		s.onAccept(client)
+	}
+}
+func (s *Server) handleClose(c *websocket.Conn, err error) {
+	if client := s.remove(c); client != nil && s.onClose != nil {
+		if err == nil {
+			err = client.closeErr
+		}
+		s.onClose(client, err)
+	}
+}
+func (s *Server) handleError(c *websocket.Conn, err error) {
+	if client := s.Client(c.ID()); client != nil && s.onError != nil {
+		s.onError(client, err)
+	}
 }
 func (s *Server) handleData(c *websocket.Conn, isBinary bool, data []byte) {
-	s.clientLock.RLock()
-	defer s.clientLock.RUnlock()
-	if client, ok := s.clients[int(c.ID())]; ok {
-		client.receiveMessage(isBinary, data)
+	client := s.Client(c.ID())
+	if client == nil {
+		return
+	}
+	client.received(data)
+	if s.maxMessageSize > 0 && len(data) > s.maxMessageSize {
+		client.closeWith(websocket.StatusTooBig, `message too big`)
+		return
+	}
+	if s.messageRate > 0 && !client.allowMessage(s.messageRate) {
+		client.closeWith(websocket.StatusViolation, `message rate exceeded`)
+		return
+	}
+	client.receiveMessage(isBinary, data)
+}
+
+func (s *Server) handlePong(c *websocket.Conn, data []byte) {
+	if client := s.Client(c.ID()); client != nil {
+		client.pong()
 	}
 }
 
@@ -55,13 +235,22 @@ func (s *Server) initWebsocket() {
 	ws.HandleClose(s.handleClose)
 	ws.HandleError(s.handleError)
 	ws.HandleData(s.handleData)
+	ws.HandlePong(s.handlePong)
 	s.ws = ws
 }
 
 func NewServer() *Server {
 	svr := &Server{
-		clients: make(map[int]*Client),
+		clients: make(map[uint64]*Client),
+		rooms:   make(map[string]map[uint64]*Client),
 	}
 	svr.initWebsocket()
 	return svr
 }
+
+// CloseAll send close frame with going away status and reason to all clients
+func (s *Server) CloseAll(reason string) {
+	for _, client := range s.Clients() {
+		client.closeWith(websocket.StatusGoAway, reason)
+	}
+}
@@ -1,25 +1,21 @@
 package websocket
 
-import (
-	"errors"
-
-	"github.com/dgrr/websocket"
-)
+import "errors"
 
 type Writer struct {
-	conn *websocket.Conn
+	client *Client
 }
 
 func (w *Writer) Write(data []byte) (int, error) {
-	if w.conn != nil {
-		return w.conn.Write(data)
+	if w.client != nil {
+		return w.client.send(data)
 	}
 	return 0, errors.New(`invalid nil socket connection`)
 }
 
 func (w *Writer) Close() error {
-	if w.conn != nil {
-		return w.conn.Close()
+	if w.client != nil {
+		return w.client.Close()
 	}
 	return nil
 }
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	dgrr "github.com/dgrr/websocket"
+	"github.com/eqto/api-server/websocket"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// upgradeRaw send upgrade request offering extensions, frames read using returned reader
+func upgradeRaw(t *testing.T, ln *fasthttputil.InmemoryListener, path, extensions string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, e := ln.Dial()
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req := "GET " + path + " HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"
+	if extensions != `` {
+		req += "Sec-WebSocket-Extensions: " + extensions + "\r\n"
+	}
+	if _, e := conn.Write([]byte(req + "\r\n")); e != nil {
+		t.Fatal(e)
+	}
+	br := bufio.NewReader(conn)
+	resp, e := http.ReadResponse(br, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf(`expected status 101, got %d`, resp.StatusCode)
+	}
+	return conn, br, resp
+}
+
+// writeRawFrame write masked frame, code 0 for continuation
+func writeRawFrame(t *testing.T, conn net.Conn, code dgrr.Code, fin, rsv1 bool, payload []byte) {
+	t.Helper()
+	fr := dgrr.AcquireFrame()
+	defer dgrr.ReleaseFrame(fr)
+	fr.SetCode(code)
+	if fin {
+		fr.SetFin()
+	}
+	if rsv1 {
+		fr.SetRSV1()
+	}
+	fr.SetPayload(payload)
+	fr.Mask()
+	if _, e := fr.WriteTo(conn); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func readRawFrame(t *testing.T, conn net.Conn, br *bufio.Reader) *dgrr.Frame {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	fr := dgrr.AcquireFrame()
+	t.Cleanup(func() { dgrr.ReleaseFrame(fr) })
+	if _, e := fr.ReadFrom(br); e != nil {
+		t.Fatal(e)
+	}
+	return fr
+}
+
+func compressTest(t *testing.T, data []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	w.Write(data)
+	w.Flush()
+	return bytes.TrimSuffix(buf.Bytes(), []byte{0x00, 0x00, 0xff, 0xff})
+}
+
+func decompressTest(t *testing.T, data []byte) []byte {
+	t.Helper()
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader([]byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff})))
+	out, e := io.ReadAll(r)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return out
+}
+
+// echoWebsocket serve websocket route /ws replying every message prefixed with echo:
+func echoWebsocket(t *testing.T, compression bool) *fasthttputil.InmemoryListener {
+	t.Helper()
+	s := newTestServer()
+	ws := s.HandleWebsocket(`/ws`).OnAccept(func(client *websocket.Client) {
+		client.OnMessage(func(isBinary bool, data []byte, w *websocket.Writer) {
+			w.Write(append([]byte(`echo:`), data...))
+		})
+	})
+	if compression {
+		ws.Compression()
+	}
+	return serveTestListener(t, s)
+}
+
+func TestWebsocketCompression(t *testing.T) {
+	ln := echoWebsocket(t, true)
+	conn, br, resp := upgradeRaw(t, ln, `/ws`, `x-webkit-deflate-frame, permessage-deflate; client_max_window_bits`)
+	if ext := resp.Header.Get(`Sec-WebSocket-Extensions`); ext != `permessage-deflate; server_no_context_takeover; client_no_context_takeover` {
+		t.Fatalf(`expected permessage-deflate accepted, got %q`, ext)
+	}
+
+	// compressed message fragmented into two frames, RSV1 set on first frame only
+	msg := []byte(strings.Repeat(`hello websocket `, 10))
+	compressed := compressTest(t, msg)
+	writeRawFrame(t, conn, dgrr.CodeText, false, true, compressed[:len(compressed)/2])
+	writeRawFrame(t, conn, dgrr.CodeContinuation, true, false, compressed[len(compressed)/2:])
+	fr := readRawFrame(t, conn, br)
+	if !fr.HasRSV1() {
+		t.Fatal(`expected compressed reply`)
+	}
+	if reply := decompressTest(t, fr.Payload()); string(reply) != `echo:`+string(msg) {
+		t.Errorf(`unexpected reply %q`, reply)
+	}
+
+	writeRawFrame(t, conn, dgrr.CodeText, true, false, []byte(`hi`))
+	fr = readRawFrame(t, conn, br)
+	if fr.HasRSV1() || string(fr.Payload()) != `echo:hi` {
+		t.Errorf(`expected uncompressed reply echo:hi, got compressed %v %q`, fr.HasRSV1(), fr.Payload())
+	}
+
+	writeRawFrame(t, conn, dgrr.CodePing, true, false, []byte(`p`))
+	if fr = readRawFrame(t, conn, br); !fr.IsPong() || string(fr.Payload()) != `p` {
+		t.Errorf(`expected pong, got %s`, fr.Code())
+	}
+}
+
+func TestWebsocketCompressionDeclined(t *testing.T) {
+	tests := []struct {
+		compression bool
+		extensions  string
+	}{
+		{false, `permessage-deflate`},
+		{true, ``},
+		{true, `permessage-deflate; server_max_window_bits=10`},
+	}
+	for _, test := range tests {
+		ln := echoWebsocket(t, test.compression)
+		conn, br, resp := upgradeRaw(t, ln, `/ws`, test.extensions)
+		name := fmt.Sprintf(`compression %v, offer %q`, test.compression, test.extensions)
+		if ext := resp.Header.Get(`Sec-WebSocket-Extensions`); ext != `` {
+			t.Errorf(`%s: expected extension declined, got %q`, name, ext)
+		}
+		if !test.compression {
+			continue
+		}
+		// compressed frame without negotiated extension rejected
+		writeRawFrame(t, conn, dgrr.CodeText, true, true, compressTest(t, []byte(`hello`)))
+		if fr := readRawFrame(t, conn, br); !fr.IsClose() || fr.Status() != dgrr.StatusProtocolError {
+			t.Errorf(`%s: expected close with protocol error, got %s %s`, name, fr.Code(), fr.Status())
+		}
+	}
+}
+
+func TestWebsocketCompressionCloseByClient(t *testing.T) {
+	s := newTestServer()
+	closed := make(chan error, 1)
+	s.HandleWebsocket(`/ws`).Compression().OnClose(func(client *websocket.Client, err error) { closed <- err })
+	ln := serveTestListener(t, s)
+	conn, br, _ := upgradeRaw(t, ln, `/ws`, `permessage-deflate`)
+
+	fr := dgrr.AcquireFrame()
+	fr.SetClose()
+	fr.SetStatus(dgrr.StatusGoAway)
+	fr.SetFin()
+	fr.Write([]byte(`bye`))
+	fr.Mask()
+	if _, e := fr.WriteTo(conn); e != nil {
+		t.Fatal(e)
+	}
+	dgrr.ReleaseFrame(fr)
+	if fr := readRawFrame(t, conn, br); !fr.IsClose() || fr.Status() != dgrr.StatusGoAway {
+		t.Errorf(`expected close reply with status 1001, got %s %s`, fr.Code(), fr.Status())
+	}
+	select {
+	case e := <-closed:
+		if wsErr, ok := e.(dgrr.Error); !ok || wsErr.Status != dgrr.StatusGoAway || wsErr.Reason != `bye` {
+			t.Errorf(`expected close error with status 1001 and reason bye, got %v`, e)
+		}
+	case <-time.After(time.Second):
+		t.Error(`OnClose not called`)
+	}
+}